@@ -26,6 +26,9 @@ type Clients struct {
 	Definer     *Definer
 	Repetitions *Repetition
 	Settings    *SettingsConfig
+	Commands    *CommandStore
+	Usage       *UsageFetcher
+	Reminders   *Reminder
 }
 
 // TODO: Can I not extract word from the message? m.Text?
@@ -36,9 +39,7 @@ func flipWordCard(c *Clients, word string, m *Message, ks []*InlineKeyboard) err
 	if err != nil {
 		return fmt.Errorf("retrieving definition: %v", err)
 	}
-	if ks == nil {
-		ks = []*InlineKeyboard{}
-	}
+	ks = append(ks, EditCallback{word}.AsInlineKeyboard())
 	r := &EditMessageText{
 		ChatId:    m.Chat.Id,
 		MessageId: m.Id,
@@ -0,0 +1,432 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Import and export of Anki packages (.apkg). Package is a zip with a sqlite
+// collection, see https://github.com/ankitects/anki for the schema.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Collection in the legacy format, which is readable by all Anki versions.
+	ankiCollection = "collection.anki2"
+	// Collection written by Anki 2.1 with the same schema.
+	ankiCollection21 = "collection.anki21"
+	// Zstd compressed collection of the newest Anki versions.
+	ankiCollection21b = "collection.anki21b"
+	// Anki separates fields of a note with this character.
+	ankiFieldSeparator = "\x1f"
+	// Anki card types.
+	ankiTypeNew    = 0
+	ankiTypeReview = 2
+	// Queue of the suspended cards, otherwise queue is the same as the type.
+	ankiQueueSuspended = -1
+	// Ease factor of the new cards in permille.
+	ankiDefaultFactor = 2500
+)
+
+// maxAnkiCollectionSize limits the extracted collection, which compresses
+// well, so that small packages can't fill the disk. Tests can change it.
+var maxAnkiCollectionSize int64 = 256 << 20
+
+var (
+	ankiLineBreakRe = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>`)
+	ankiTagRe       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ankiText converts html of the Anki field into plain text.
+func ankiText(f string) string {
+	f = ankiLineBreakRe.ReplaceAllString(f, "\n")
+	f = ankiTagRe.ReplaceAllString(f, "")
+	return strings.TrimSpace(html.UnescapeString(f))
+}
+
+// ankiHTML converts plain text into html of the Anki field.
+func ankiHTML(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
+
+// openAnkiCollection extracts the collection from the package into the
+// directory and opens it.
+func openAnkiCollection(data []byte, dir string) (*sql.DB, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading package: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	f := files[ankiCollection21]
+	if f == nil {
+		f = files[ankiCollection]
+	}
+	if f == nil {
+		if files[ankiCollection21b] != nil {
+			return nil, fmt.Errorf("package is in the newest Anki format, please export it with \"Support older Anki versions\" checked")
+		}
+		return nil, fmt.Errorf("package doesn't contain a collection")
+	}
+	tooLarge := fmt.Errorf("collection is larger than %d MB", maxAnkiCollectionSize>>20)
+	if f.UncompressedSize64 > uint64(maxAnkiCollectionSize) {
+		return nil, tooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	path := filepath.Join(dir, ankiCollection)
+	w, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	// Size in the header isn't trusted, at most one byte over the limit is
+	// read to find out if the collection is larger.
+	n, err := io.Copy(w, io.LimitReader(rc, maxAnkiCollectionSize+1))
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	if n > maxAnkiCollectionSize {
+		w.Close()
+		return nil, tooLarge
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlite3", path)
+}
+
+// ParseApkg reads notes of the Anki package, first field of a note is the
+// front and second one is the back. Suspended cards stay suspended. Interval,
+// ease and lapses of the reviewed cards are kept, stage is chosen by stageFor.
+// Notes which couldn't be read are returned separately.
+func ParseApkg(data []byte, stageFor func(time.Duration) int) ([]*CardRecord, []string, error) {
+	dir, err := ioutil.TempDir("", "apkg")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+	db, err := openAnkiCollection(data, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	var crt int64
+	if err := db.QueryRow(`SELECT crt FROM col`).Scan(&crt); err != nil {
+		return nil, nil, fmt.Errorf("reading collection: %w", err)
+	}
+	// Only the first card of the note is considered, as the rest are usually
+	// reverse cards.
+	rows, err := db.Query(`
+		SELECT n.id, n.flds, n.tags, c.type, c.queue, c.due, c.ivl, c.factor, c.lapses
+		FROM notes n
+		LEFT JOIN cards c ON c.nid = n.id AND c.ord = 0
+		ORDER BY n.id`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading notes: %w", err)
+	}
+	defer rows.Close()
+	var (
+		cards  []*CardRecord
+		failed []string
+	)
+	for rows.Next() {
+		var (
+			id                                   int64
+			flds, tags                           string
+			typ, queue, due, ivl, factor, lapses sql.NullInt64
+		)
+		if err := rows.Scan(&id, &flds, &tags, &typ, &queue, &due, &ivl, &factor, &lapses); err != nil {
+			return nil, nil, err
+		}
+		fs := strings.Split(flds, ankiFieldSeparator)
+		if len(fs) < 2 {
+			failed = append(failed, fmt.Sprintf("note %d: got %d fields; want at least 2", id, len(fs)))
+			continue
+		}
+		c := &CardRecord{
+			Front:     ankiText(fs[0]),
+			Back:      ankiText(fs[1]),
+			Tags:      ParseTags(tags),
+			Suspended: queue.Valid && queue.Int64 == ankiQueueSuspended,
+		}
+		if c.Front == "" || c.Back == "" {
+			failed = append(failed, fmt.Sprintf("note %d: front and back can't be empty", id))
+			continue
+		}
+		// Due of review cards is the number of days since the collection
+		// was created.
+		if typ.Int64 == ankiTypeReview && ivl.Int64 > 0 {
+			interval := time.Duration(ivl.Int64) * day
+			c.Due = time.Unix(crt, 0).Add(time.Duration(due.Int64) * day)
+			c.Schedule = &Card{
+				Stage:      stageFor(interval),
+				Interval:   interval,
+				Lapses:     int(lapses.Int64),
+				LastReview: c.Due.Add(-interval),
+				Ease:       float64(factor.Int64) / 1000,
+			}
+			if c.Schedule.Ease == 0 {
+				c.Schedule.Ease = sm2InitialEase
+			}
+		}
+		cards = append(cards, c)
+	}
+	return cards, failed, rows.Err()
+}
+
+const ankiSchema = `
+	CREATE TABLE col (
+		id integer primary key, crt integer not null, mod integer not null,
+		scm integer not null, ver integer not null, dty integer not null,
+		usn integer not null, ls integer not null, conf text not null,
+		models text not null, decks text not null, dconf text not null,
+		tags text not null
+	);
+	CREATE TABLE notes (
+		id integer primary key, guid text not null, mid integer not null,
+		mod integer not null, usn integer not null, tags text not null,
+		flds text not null, sfld integer not null, csum integer not null,
+		flags integer not null, data text not null
+	);
+	CREATE TABLE cards (
+		id integer primary key, nid integer not null, did integer not null,
+		ord integer not null, mod integer not null, usn integer not null,
+		type integer not null, queue integer not null, due integer not null,
+		ivl integer not null, factor integer not null, reps integer not null,
+		lapses integer not null, left integer not null, odue integer not null,
+		odid integer not null, flags integer not null, data text not null
+	);
+	CREATE TABLE revlog (
+		id integer primary key, cid integer not null, usn integer not null,
+		ease integer not null, ivl integer not null, lastIvl integer not null,
+		factor integer not null, time integer not null, type integer not null
+	);
+	CREATE TABLE graves (
+		usn integer not null, oid integer not null, type integer not null
+	);
+	CREATE INDEX ix_notes_usn on notes (usn);
+	CREATE INDEX ix_cards_usn on cards (usn);
+	CREATE INDEX ix_revlog_usn on revlog (usn);
+	CREATE INDEX ix_cards_nid on cards (nid);
+	CREATE INDEX ix_cards_sched on cards (did, queue, due);
+	CREATE INDEX ix_revlog_cid on revlog (cid);
+	CREATE INDEX ix_notes_csum on notes (csum);`
+
+// ankiModel is the "Basic" note type with front and back fields.
+func ankiModel(id int64, now time.Time) map[string]interface{} {
+	field := func(name string, ord int) map[string]interface{} {
+		return map[string]interface{}{
+			"name": name, "ord": ord, "sticky": false, "rtl": false,
+			"font": "Arial", "size": 20, "media": []string{},
+		}
+	}
+	return map[string]interface{}{
+		"id": id, "name": "Basic", "type": 0, "mod": now.Unix(), "usn": -1,
+		"sortf": 0, "did": 1, "tags": []string{}, "vers": []int{},
+		"flds": []interface{}{field("Front", 0), field("Back", 1)},
+		"tmpls": []interface{}{map[string]interface{}{
+			"name": "Card 1", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+			"qfmt": "{{Front}}",
+			"afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}",
+		}},
+		"css":       ".card {\n font-family: arial;\n font-size: 20px;\n text-align: center;\n color: black;\n background-color: white;\n}\n",
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+	}
+}
+
+func ankiDeck(id int64, name string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "name": name, "conf": 1, "mod": now.Unix(), "usn": -1,
+		"desc": "", "dyn": 0, "collapsed": false, "extendNew": 10, "extendRev": 50,
+		"newToday": []int{0, 0}, "revToday": []int{0, 0},
+		"lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+	}
+}
+
+var ankiDeckConf = map[string]interface{}{
+	"1": map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60,
+		"autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new": map[string]interface{}{
+			"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": ankiDefaultFactor,
+			"order": 1, "perDay": 20, "bury": true, "separate": true,
+		},
+		"lapse": map[string]interface{}{
+			"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+		},
+		"rev": map[string]interface{}{
+			"perDay": 100, "ease4": 1.3, "fuzz": 0.05, "minSpace": 1,
+			"ivlFct": 1, "maxIvl": 36500, "bury": true,
+		},
+	},
+}
+
+// ankiChecksum is the first 8 hex digits of sha1 of the sort field.
+func ankiChecksum(s string) int64 {
+	h := sha1.Sum([]byte(s))
+	v, _ := strconv.ParseInt(hex.EncodeToString(h[:4]), 16, 64)
+	return v
+}
+
+// WriteApkg creates an Anki package with the cards. Decks of the cards and
+// suspension are kept, cards with a schedule are exported as review cards.
+func WriteApkg(cards []*CardRecord, now time.Time) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "apkg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ankiCollection)
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if _, err := db.Exec(ankiSchema); err != nil {
+		return nil, fmt.Errorf("creating collection: %w", err)
+	}
+
+	// Collection is created at the start of the day, so that due days are
+	// whole.
+	crt := now.Truncate(day)
+	base := now.UnixNano() / int64(time.Millisecond)
+	modelID := base
+	deckIDs := map[string]int64{DefaultDeck: 1}
+	decks := map[string]interface{}{"1": ankiDeck(1, DefaultDeck, now)}
+	for _, c := range cards {
+		if _, ok := deckIDs[c.Deck]; ok || c.Deck == "" {
+			continue
+		}
+		id := base + int64(len(deckIDs))
+		deckIDs[c.Deck] = id
+		decks[fmt.Sprint(id)] = ankiDeck(id, c.Deck, now)
+	}
+	models, err := json.Marshal(map[string]interface{}{fmt.Sprint(modelID): ankiModel(modelID, now)})
+	if err != nil {
+		return nil, err
+	}
+	ds, err := json.Marshal(decks)
+	if err != nil {
+		return nil, err
+	}
+	dconf, err := json.Marshal(ankiDeckConf)
+	if err != nil {
+		return nil, err
+	}
+	conf := fmt.Sprintf(`{"nextPos": %d, "estTimes": true, "activeDecks": [1], "sortType": "noteFld", "timeLim": 0, "sortBackwards": false, "addToCur": true, "curDeck": 1, "newBury": true, "newSpread": 0, "dueCounts": true, "curModel": "%d", "collapseTime": 1200}`, len(cards)+1, modelID)
+	if _, err := db.Exec(`
+		INSERT INTO col VALUES(1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		crt.Unix(), now.Unix(), base, conf, string(models), string(ds), string(dconf)); err != nil {
+		return nil, fmt.Errorf("creating collection: %w", err)
+	}
+
+	for i, c := range cards {
+		nid := base + int64(i)
+		h := sha1.Sum([]byte(c.Front))
+		guid := base64.RawStdEncoding.EncodeToString(h[:8])
+		tags := ""
+		if len(c.Tags) > 0 {
+			tags = " " + strings.Join(c.Tags, " ") + " "
+		}
+		if _, err := db.Exec(`
+			INSERT INTO notes VALUES(?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			nid, guid, modelID, now.Unix(), tags,
+			ankiHTML(c.Front)+ankiFieldSeparator+ankiHTML(c.Back), c.Front, ankiChecksum(c.Front)); err != nil {
+			return nil, fmt.Errorf("exporting %q: %w", c.Front, err)
+		}
+		did := deckIDs[c.Deck]
+		if did == 0 {
+			did = 1
+		}
+		typ, due, ivl, factor, reps, lapses := ankiTypeNew, int64(i+1), int64(0), 0, 0, 0
+		if s := c.Schedule; s != nil && s.Stage > 0 {
+			typ = ankiTypeReview
+			due = int64(c.Due.Sub(crt) / day)
+			ivl = int64(s.Interval / day)
+			if ivl < 1 {
+				ivl = 1
+			}
+			factor = int(math.Round(s.Ease * 1000))
+			if factor == 0 {
+				factor = ankiDefaultFactor
+			}
+			reps, lapses = s.Stage, s.Lapses
+		}
+		queue := typ
+		if c.Suspended {
+			queue = ankiQueueSuspended
+		}
+		if _, err := db.Exec(`
+			INSERT INTO cards VALUES(?, ?, ?, 0, ?, -1, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0, 0, '')`,
+			nid, nid, did, now.Unix(), typ, queue, due, ivl, factor, reps, lapses); err != nil {
+			return nil, fmt.Errorf("exporting %q: %w", c.Front, err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+
+	collection, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{ankiCollection, collection},
+		// No media files are exported.
+		{"media", []byte("{}")},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
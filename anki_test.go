@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApkg(t *testing.T) {
+	now := time.Date(2020, 5, 10, 15, 0, 0, 0, time.UTC)
+	due := time.Date(2020, 5, 20, 0, 0, 0, 0, time.UTC)
+	cards := []*CardRecord{{
+		Front: "fekete",
+		Back:  "black <dark>\nnot white",
+		Tags:  []string{"colors"},
+		Deck:  "Hungarian",
+	}, {
+		Front: "kutya",
+		Back:  "dog",
+		Schedule: &Card{
+			Stage:    2,
+			Interval: 4 * day,
+			Lapses:   1,
+			Ease:     2.3,
+		},
+		Due: due,
+	}, {
+		Front:     "macska",
+		Back:      "cat",
+		Suspended: true,
+	}}
+	data, err := WriteApkg(cards, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stageFor := func(d time.Duration) int {
+		return int(d / day)
+	}
+	got, failed, err := ParseApkg(data, stageFor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) > 0 {
+		t.Errorf("failed: %q", failed)
+	}
+	// Decks are chosen on import.
+	want := []*CardRecord{{
+		Front: "fekete",
+		Back:  "black <dark>\nnot white",
+		Tags:  []string{"colors"},
+	}, {
+		Front: "kutya",
+		Back:  "dog",
+		Schedule: &Card{
+			Stage:      4,
+			Interval:   4 * day,
+			Lapses:     1,
+			Ease:       2.3,
+			LastReview: due.Add(-4 * day),
+		},
+		Due: due,
+	}, {
+		Front:     "macska",
+		Back:      "cat",
+		Suspended: true,
+	}}
+	for _, c := range got {
+		if c.Schedule != nil {
+			c.Due = c.Due.UTC()
+			c.Schedule.LastReview = c.Schedule.LastReview.UTC()
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+		for i := range got {
+			t.Logf("got[%d] = %+v, schedule %+v", i, got[i], got[i].Schedule)
+		}
+	}
+
+	if _, _, err := ParseApkg([]byte("not a zip"), stageFor); err == nil {
+		t.Errorf("ParseApkg(not a zip): got nil error")
+	}
+
+	defer func(max int64) { maxAnkiCollectionSize = max }(maxAnkiCollectionSize)
+	maxAnkiCollectionSize = 1 << 10
+	if _, _, err := ParseApkg(data, stageFor); err == nil {
+		t.Errorf("ParseApkg(collection over %d bytes): got nil error", maxAnkiCollectionSize)
+	}
+}
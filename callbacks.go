@@ -13,7 +13,13 @@
 // limitations under the License.
 package main
 
-import "fmt"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 type KnowCallback struct {
 	Word string
@@ -25,7 +31,7 @@ func (KnowCallback) Call(s *State, q *CallbackQuery) error {
 	word := CallbackInfoFromString(q.Data).Word
 
 	// TODO: Need to handle 2 rapid taps to avoid saving it as known 2 times in a row.
-	if err := s.Repetitions.AnswerKnow(chatID, word); err != nil {
+	if err := s.answer(chatID, word, Forward, GradeGood, q.Message.SentAt()); err != nil {
 		return err
 	}
 
@@ -62,7 +68,12 @@ func (DontKnowCallback) Call(s *State, q *CallbackQuery) error {
 	chatID := q.Message.Chat.Id
 	word := info.Word
 
-	if err := s.Repetitions.AnswerDontKnow(chatID, word); err != nil {
+	// Latency is only meaningful for answers to the practice card.
+	var shown time.Time
+	if info.Action == PracticeDontKnowAction {
+		shown = q.Message.SentAt()
+	}
+	if err := s.answer(chatID, word, Forward, GradeAgain, shown); err != nil {
 		return err
 	}
 
@@ -95,25 +106,75 @@ func (c DontKnowCallback) AsInlineKeyboard() *InlineKeyboard {
 	}
 }
 
+// GradeCallback is a graded alternative to know/don't know used by adaptive
+// schedulers.
+type GradeCallback struct {
+	Word  string
+	Grade Grade
+}
+
+func (GradeCallback) Call(s *State, q *CallbackQuery) error {
+	info := CallbackInfoFromString(q.Data)
+	defer s.Telegram.AnswerCallbackLog(q.Id, info.Grade.String())
+	chatID := q.Message.Chat.Id
+	word := info.Word
+
+	if err := s.answer(chatID, word, Forward, info.Grade, q.Message.SentAt()); err != nil {
+		return err
+	}
+
+	var ks []*InlineKeyboard
+	if info.Grade != GradeAgain {
+		ks = append(ks, DontKnowCallback{word, false}.AsInlineKeyboard())
+	}
+	if err := flipWordCard(s.Clients, word, q.Message, ks); err != nil {
+		return err
+	}
+	return practiceReply(s, chatID)
+}
+
+func (GradeCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == PracticeGradeAction
+}
+
+func (c GradeCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: c.Grade.String(),
+		CallbackData: CallbackInfo{
+			Action: PracticeGradeAction,
+			Word:   c.Word,
+			Grade:  c.Grade,
+		}.String(),
+	}
+}
+
+// ResetProgressCallback starts learning the word from the beginning. It isn't
+// an answer, so unlike "Don't know" it's neither a lapse nor a review.
 type ResetProgressCallback struct {
 	Word string
 }
 
-// ResetProgress type is just a convenience placeholder to create inline keyboards.
-// Logic will be handled by dont know callback.
-func (ResetProgressCallback) Call(_ *State, _ *CallbackQuery) error {
-	return nil
+func (ResetProgressCallback) Call(s *State, q *CallbackQuery) error {
+	defer s.Telegram.AnswerCallbackLog(q.Id, "Reset progress")
+	chatID := q.Message.Chat.Id
+	word := CallbackInfoFromString(q.Data).Word
+	if err := s.Repetitions.ResetProgress(chatID, word, Forward); err != nil {
+		return err
+	}
+	return flipWordCard(s.Clients, word, q.Message, nil)
 }
 
-func (ResetProgressCallback) Match(_ *State, _ *CallbackQuery) bool {
-	return false
+func (ResetProgressCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == ResetProgressAction
 }
 
 func (c ResetProgressCallback) AsInlineKeyboard() *InlineKeyboard {
 	return &InlineKeyboard{
 		Text: "Reset progress",
 		CallbackData: CallbackInfo{
-			Action: PracticeDontKnowActionNoPractice,
+			Action: ResetProgressAction,
 			Word:   c.Word,
 		}.String(),
 	}
@@ -127,25 +188,48 @@ func (LearnCallback) Call(s *State, q *CallbackQuery) error {
 	// FIXME: Next 3 lines are very common.
 	chatID := q.Message.Chat.Id
 	word := CallbackInfoFromString(q.Data).Word
-	if err := s.Repetitions.Save(chatID, word, q.Message.Text); err != nil {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
 		return err
 	}
-	m := q.Message
+	err = s.Repetitions.Save(chatID, settings.ActiveDeck(), word, q.Message.Text)
+	if err == ErrWordExists {
+		s.Telegram.AnswerCallbackLog(q.Id, fmt.Sprintf("%q is already saved for learning", word))
+		return editReplyMarkup(s, q.Message, MergeCallbacks(word))
+	}
+	if err != nil {
+		return err
+	}
+	if err := editReplyMarkup(s, q.Message, []Callback{TagCallback{word}}); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Saved %q for learning to deck %q", word, settings.ActiveDeck())
+	s.Telegram.AnswerCallbackLog(q.Id, msg)
+	return nil
+}
+
+// editReplyMarkup replaces buttons of the message.
+func editReplyMarkup(s *State, m *Message, cs []Callback) error {
+	ks := []*InlineKeyboard{}
+	for _, c := range cs {
+		ks = append(ks, c.AsInlineKeyboard())
+	}
+	return editKeyboard(s, m, [][]*InlineKeyboard{ks})
+}
+
+// editKeyboard replaces buttons of the message with rows of buttons.
+func editKeyboard(s *State, m *Message, rows [][]*InlineKeyboard) error {
 	r := &EditMessageText{
 		ChatId:    m.Chat.Id,
 		MessageId: m.Id,
 		ReplyMarkup: ReplyMarkup{
-			InlineKeyboard: [][]*InlineKeyboard{
-				[]*InlineKeyboard{},
-			},
+			InlineKeyboard: rows,
 		},
 	}
 	var rm Message
 	if err := s.Telegram.Call("editMessageReplyMarkup", r, &rm); err != nil {
 		return fmt.Errorf("editing message reply markup: %w", err)
 	}
-	msg := fmt.Sprintf("Saved %q for learning", word)
-	s.Telegram.AnswerCallbackLog(q.Id, msg)
 	return nil
 }
 
@@ -163,3 +247,494 @@ func (c LearnCallback) AsInlineKeyboard() *InlineKeyboard {
 		}.String(),
 	}
 }
+
+// QuizCallback is one of the options of the multiple choice quiz.
+type QuizCallback struct {
+	Option int
+}
+
+func (QuizCallback) Call(s *State, q *CallbackQuery) error {
+	info := CallbackInfoFromString(q.Data)
+	chatID := q.Message.Chat.Id
+
+	quiz, err := loadQuiz(s, chatID)
+	if err != nil {
+		return err
+	}
+	// Buttons of the earlier questions stay in the chat, the question is
+	// the first line of the message.
+	if quiz == nil || strings.SplitN(q.Message.Text, "\n", 2)[0] != quiz.word {
+		s.Telegram.AnswerCallbackLog(q.Id, "This question is over")
+		return nil
+	}
+	word := quiz.word
+	g, msg := GradeAgain, "Wrong"
+	if info.Option == quiz.correct {
+		g, msg = GradeGood, "Correct"
+	}
+	defer s.Telegram.AnswerCallbackLog(q.Id, msg)
+	if err := s.answer(chatID, word, Forward, g, q.Message.SentAt()); err != nil {
+		return err
+	}
+
+	var ks []*InlineKeyboard
+	if g != GradeAgain {
+		ks = append(ks, DontKnowCallback{word, false}.AsInlineKeyboard())
+	}
+	if err := flipWordCard(s.Clients, word, q.Message, ks); err != nil {
+		return err
+	}
+	// The quiz is over when there is no next question.
+	next, err := quiz.askNext(s, chatID)
+	var sc *SerializedCommand
+	if next != nil {
+		sc = next.Serialize()
+	}
+	if saveErr := s.SaveCommand(chatID, sc); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+func (QuizCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == PracticeQuizAction
+}
+
+func (c QuizCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: fmt.Sprint(c.Option),
+		CallbackData: CallbackInfo{
+			Action: PracticeQuizAction,
+			Option: c.Option,
+		}.String(),
+	}
+}
+
+// ReverseGradeCallback grades recall of the word from its definition.
+type ReverseGradeCallback struct {
+	Word  string
+	Grade Grade
+}
+
+func (ReverseGradeCallback) Call(s *State, q *CallbackQuery) error {
+	info := CallbackInfoFromString(q.Data)
+	defer s.Telegram.AnswerCallbackLog(q.Id, info.Grade.String())
+	chatID := q.Message.Chat.Id
+	word := info.Word
+
+	if err := s.answer(chatID, word, Reverse, info.Grade, q.Message.SentAt()); err != nil {
+		return err
+	}
+	if err := flipWordCard(s.Clients, word, q.Message, nil); err != nil {
+		return err
+	}
+	return reverseReply(s, chatID)
+}
+
+func (ReverseGradeCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == PracticeReverseGradeAction
+}
+
+func (c ReverseGradeCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: c.Grade.String(),
+		CallbackData: CallbackInfo{
+			Action: PracticeReverseGradeAction,
+			Word:   c.Word,
+			Grade:  c.Grade,
+		}.String(),
+	}
+}
+
+// TagCallback asks user for tags of the word.
+type TagCallback struct {
+	Word string
+}
+
+func (TagCallback) Call(s *State, q *CallbackQuery) error {
+	defer s.Telegram.AnswerCallbackLog(q.Id, "")
+	chatID := q.Message.Chat.Id
+	word := CallbackInfoFromString(q.Data).Word
+
+	// The button might be on an old message of a deleted word.
+	if err := checkSaved(s, chatID, word); err != nil {
+		return err
+	}
+	c := &tagCommand{name: "/tag", word: word}
+	if err := c.ask(s, chatID); err != nil {
+		return err
+	}
+	return s.SaveCommand(chatID, c.Serialize())
+}
+
+func (TagCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == TagAction
+}
+
+func (c TagCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: "Tag",
+		CallbackData: CallbackInfo{
+			Action: TagAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// EditCallback starts /edit of the word.
+type EditCallback struct {
+	Word string
+}
+
+func (EditCallback) Call(s *State, q *CallbackQuery) error {
+	defer s.Telegram.AnswerCallbackLog(q.Id, "")
+	chatID := q.Message.Chat.Id
+	word := CallbackInfoFromString(q.Data).Word
+
+	if err := checkSaved(s, chatID, word); err != nil {
+		return err
+	}
+	c := EditCommandFactory()("/edit").(*multiQuestionCommand)
+	c.questions[0].answer = word
+	cmd, err := c.askNext(s, chatID)
+	if err != nil {
+		return err
+	}
+	return s.SaveCommand(chatID, cmd.Serialize())
+}
+
+func (EditCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == EditAction
+}
+
+func (c EditCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: "Edit",
+		CallbackData: CallbackInfo{
+			Action: EditAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// MergeCallback decides what to do with the new definition of the word which
+// is already saved. The new definition is the text of the message.
+type MergeCallback struct {
+	Word   string
+	Action CallbackAction
+}
+
+// MergeCallbacks returns all choices for the new definition of the word.
+func MergeCallbacks(word string) []Callback {
+	return []Callback{
+		MergeCallback{word, KeepDefinitionAction},
+		MergeCallback{word, ReplaceDefinitionAction},
+		MergeCallback{word, AppendDefinitionAction},
+	}
+}
+
+func (MergeCallback) Call(s *State, q *CallbackQuery) error {
+	info := CallbackInfoFromString(q.Data)
+	chatID := q.Message.Chat.Id
+	word := info.Word
+
+	def, err := s.Repetitions.GetDefinition(chatID, word)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted in the meantime.
+		return checkSaved(s, chatID, word)
+	}
+	if err != nil {
+		return err
+	}
+	msg := "Kept existing definition"
+	switch info.Action {
+	case ReplaceDefinitionAction:
+		def = q.Message.Text
+		msg = "Replaced definition"
+	case AppendDefinitionAction:
+		def += "\n\n" + q.Message.Text
+		msg = "Appended definition"
+	}
+	defer s.Telegram.AnswerCallbackLog(q.Id, msg)
+	if info.Action != KeepDefinitionAction {
+		if err := s.Repetitions.Edit(chatID, word, word, def); err != nil {
+			return err
+		}
+	}
+	return editReplyMarkup(s, q.Message, []Callback{TagCallback{word}})
+}
+
+func (MergeCallback) Match(_ *State, q *CallbackQuery) bool {
+	switch CallbackInfoFromString(q.Data).Action {
+	case KeepDefinitionAction, ReplaceDefinitionAction, AppendDefinitionAction:
+		return true
+	}
+	return false
+}
+
+func (c MergeCallback) AsInlineKeyboard() *InlineKeyboard {
+	text := map[CallbackAction]string{
+		KeepDefinitionAction:    "Keep existing",
+		ReplaceDefinitionAction: "Replace definition",
+		AppendDefinitionAction:  "Append definition",
+	}[c.Action]
+	return &InlineKeyboard{
+		Text: text,
+		CallbackData: CallbackInfo{
+			Action: c.Action,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// SuspendCallback excludes the word from practice.
+type SuspendCallback struct {
+	Word string
+	// If true another practice card will be shown.
+	Practice bool
+	// If true the next card is practiced in reverse.
+	Reverse bool
+}
+
+func (SuspendCallback) Call(s *State, q *CallbackQuery) error {
+	info := CallbackInfoFromString(q.Data)
+	chatID := q.Message.Chat.Id
+	word := info.Word
+
+	if err := s.Repetitions.Suspend(chatID, word, true); err != nil {
+		return err
+	}
+	s.Telegram.AnswerCallbackLog(q.Id, fmt.Sprintf("Suspended %q, see /suspended", word))
+	if err := editReplyMarkup(s, q.Message, nil); err != nil {
+		return err
+	}
+	switch info.Action {
+	case PracticeSuspendAction:
+		return practiceReply(s, chatID)
+	case PracticeReverseSuspendAction:
+		return reverseReply(s, chatID)
+	}
+	return nil
+}
+
+func (SuspendCallback) Match(_ *State, q *CallbackQuery) bool {
+	switch CallbackInfoFromString(q.Data).Action {
+	case SuspendAction, PracticeSuspendAction, PracticeReverseSuspendAction:
+		return true
+	}
+	return false
+}
+
+func (c SuspendCallback) AsInlineKeyboard() *InlineKeyboard {
+	a := SuspendAction
+	if c.Practice {
+		a = PracticeSuspendAction
+		if c.Reverse {
+			a = PracticeReverseSuspendAction
+		}
+	}
+	return &InlineKeyboard{
+		Text: "Suspend",
+		CallbackData: CallbackInfo{
+			Action: a,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// KeepLeechCallback keeps practicing the leech as it is.
+type KeepLeechCallback struct{}
+
+func (KeepLeechCallback) Call(s *State, q *CallbackQuery) error {
+	defer s.Telegram.AnswerCallbackLog(q.Id, "")
+	return editReplyMarkup(s, q.Message, nil)
+}
+
+func (KeepLeechCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == KeepLeechAction
+}
+
+func (KeepLeechCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text:         "Keep going",
+		CallbackData: CallbackInfo{Action: KeepLeechAction}.String(),
+	}
+}
+
+// BuryCallback hides the practiced word until the next day.
+type BuryCallback struct {
+	Word string
+	// If true the next card is practiced in reverse.
+	Reverse bool
+}
+
+func (BuryCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	info := CallbackInfoFromString(q.Data)
+	word := info.Word
+
+	until, err := startOfTomorrow(s, chatID, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := s.Repetitions.Bury(chatID, word, until); err != nil {
+		return err
+	}
+	s.Telegram.AnswerCallbackLog(q.Id, fmt.Sprintf("Buried %q until tomorrow", word))
+	if err := editReplyMarkup(s, q.Message, nil); err != nil {
+		return err
+	}
+	if info.Action == PracticeReverseBuryAction {
+		return reverseReply(s, chatID)
+	}
+	return practiceReply(s, chatID)
+}
+
+func (BuryCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == PracticeBuryAction || info.Action == PracticeReverseBuryAction
+}
+
+func (c BuryCallback) AsInlineKeyboard() *InlineKeyboard {
+	a := PracticeBuryAction
+	if c.Reverse {
+		a = PracticeReverseBuryAction
+	}
+	return &InlineKeyboard{
+		Text: "Bury",
+		CallbackData: CallbackInfo{
+			Action: a,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// UnsuspendCallback includes the suspended word back into practice.
+type UnsuspendCallback struct {
+	Word string
+}
+
+func (UnsuspendCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	word := CallbackInfoFromString(q.Data).Word
+
+	if err := s.Repetitions.Suspend(chatID, word, false); err != nil {
+		return err
+	}
+	s.Telegram.AnswerCallbackLog(q.Id, fmt.Sprintf("Unsuspended %q", word))
+	ws, err := s.Repetitions.Suspended(chatID)
+	if err != nil {
+		return err
+	}
+	return editKeyboard(s, q.Message, suspendedKeyboard(ws))
+}
+
+func (UnsuspendCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == UnsuspendAction
+}
+
+func (c UnsuspendCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text: "Unsuspend " + c.Word,
+		CallbackData: CallbackInfo{
+			Action: UnsuspendAction,
+			Word:   c.Word,
+		}.String(),
+	}
+}
+
+// PracticeNowCallback starts practice from the reminder, same as /practice.
+type PracticeNowCallback struct{}
+
+func (PracticeNowCallback) Call(s *State, q *CallbackQuery) error {
+	defer s.Telegram.AnswerCallbackLog(q.Id, "")
+	chatID := q.Message.Chat.Id
+	if err := editReplyMarkup(s, q.Message, nil); err != nil {
+		return err
+	}
+	// Whatever user was doing before is interrupted like by a command.
+	if err := s.SaveCommand(chatID, nil); err != nil {
+		return err
+	}
+	return practiceCommandReply(s, chatID, "")
+}
+
+func (PracticeNowCallback) Match(_ *State, q *CallbackQuery) bool {
+	info := CallbackInfoFromString(q.Data)
+	return info.Action == PracticeNowAction
+}
+
+func (PracticeNowCallback) AsInlineKeyboard() *InlineKeyboard {
+	return &InlineKeyboard{
+		Text:         "Practice now",
+		CallbackData: CallbackInfo{Action: PracticeNowAction}.String(),
+	}
+}
+
+// SnoozeCallback postpones reminders: for an hour, until tomorrow or for a
+// week.
+type SnoozeCallback struct {
+	Action CallbackAction
+}
+
+func (SnoozeCallback) Call(s *State, q *CallbackQuery) error {
+	chatID := q.Message.Chat.Id
+	action := CallbackInfoFromString(q.Data).Action
+
+	now := time.Now()
+	var until time.Time
+	var answer string
+	switch action {
+	case SnoozeHourAction:
+		until, answer = now.Add(time.Hour), "Snoozed for an hour"
+	case SnoozeTomorrowAction:
+		t, err := startOfTomorrow(s, chatID, now)
+		if err != nil {
+			return err
+		}
+		until, answer = t, "Snoozed until tomorrow"
+	case MuteWeekAction:
+		until, answer = now.Add(muteDuration), "Reminders are muted for a week"
+	case UnsnoozeAction:
+		if err := s.Reminders.Unsnooze(chatID); err != nil {
+			return err
+		}
+		s.Telegram.AnswerCallbackLog(q.Id, "Reminders are back on")
+		return editReplyMarkup(s, q.Message, []Callback{PracticeNowCallback{}})
+	default:
+		return fmt.Errorf("INTERNAL: unexpected snooze action %d", action)
+	}
+	// Only the snoozed reminder is sent again, the rest follow the schedule.
+	if err := s.Reminders.Snooze(chatID, until, action == SnoozeHourAction); err != nil {
+		return err
+	}
+	s.Telegram.AnswerCallbackLog(q.Id, answer)
+	return editReplyMarkup(s, q.Message, []Callback{PracticeNowCallback{}, SnoozeCallback{UnsnoozeAction}})
+}
+
+func (SnoozeCallback) Match(_ *State, q *CallbackQuery) bool {
+	switch CallbackInfoFromString(q.Data).Action {
+	case SnoozeHourAction, SnoozeTomorrowAction, MuteWeekAction, UnsnoozeAction:
+		return true
+	}
+	return false
+}
+
+func (c SnoozeCallback) AsInlineKeyboard() *InlineKeyboard {
+	text := map[CallbackAction]string{
+		SnoozeHourAction:     "Snooze 1h",
+		SnoozeTomorrowAction: "Snooze until tomorrow",
+		MuteWeekAction:       "Mute reminders for a week",
+		UnsnoozeAction:       "Turn reminders back on",
+	}[c.Action]
+	return &InlineKeyboard{
+		Text:         text,
+		CallbackData: CallbackInfo{Action: c.Action}.String(),
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Cloze practice, where user fills in the word missing from a usage example.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const clozeBlank = "_____"
+
+// minStemLength is the number of runes below which words aren't trimmed to
+// get the stem.
+const minStemLength = 3
+
+// Tokenizer has the rules to split sentences into words and to match
+// inflected forms of the word, they differ from language to language.
+type Tokenizer struct {
+	// Characters which are part of words in addition to letters and digits,
+	// e.g. "'" for English "don't".
+	WordCharacters string `json:"word_characters"`
+	// Number of runes at the end of the word which often change in its
+	// inflected forms, e.g. 1 for Hungarian "kutya" -> "kutyát".
+	StemTrim int `json:"stem_trim"`
+	// Maximum number of runes an inflected form can add to the stem of the
+	// word.
+	MaxSuffixLength int `json:"max_suffix_length"`
+}
+
+var defaultTokenizer = Tokenizer{StemTrim: 1, MaxSuffixLength: 4}
+
+func (t Tokenizer) validate() error {
+	if t.StemTrim < 0 || t.MaxSuffixLength < 0 {
+		return fmt.Errorf("stem_trim and max_suffix_length can't be negative")
+	}
+	return nil
+}
+
+// isWordRune reports whether r is a part of a word rather than punctuation.
+func (t Tokenizer) isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(t.WordCharacters, r)
+}
+
+// stem returns the part of the word which is expected to be kept in its
+// inflected forms.
+func (t Tokenizer) stem(word string) string {
+	w := []rune(strings.ToLower(word))
+	if len(w)-t.StemTrim >= minStemLength {
+		w = w[:len(w)-t.StemTrim]
+	}
+	return string(w)
+}
+
+// isWordForm reports whether lowercased token is likely the word or its
+// inflected form.
+func (t Tokenizer) isWordForm(token, word string) bool {
+	word = strings.ToLower(word)
+	if token == word {
+		return true
+	}
+	stem := t.stem(word)
+	return strings.HasPrefix(token, stem) &&
+		len([]rune(token))-len([]rune(stem)) <= t.MaxSuffixLength
+}
+
+// blankWord replaces forms of the word in the sentence with blanks and returns
+// the replaced forms as they were written in the sentence.
+func (t Tokenizer) blankWord(sentence, word string) (string, []string) {
+	var forms []string
+	ts := strings.Split(sentence, " ")
+	for i, tok := range ts {
+		// Punctuation is kept around the blank, the same way it's dropped
+		// when Words index is built.
+		start := strings.IndexFunc(tok, t.isWordRune)
+		if start < 0 {
+			continue
+		}
+		end := strings.LastIndexFunc(tok, t.isWordRune)
+		_, size := utf8.DecodeRuneInString(tok[end:])
+		end += size
+		core := tok[start:end]
+		if !t.isWordForm(strings.ToLower(core), word) {
+			continue
+		}
+		forms = append(forms, core)
+		ts[i] = tok[:start] + clozeBlank + tok[end:]
+	}
+	return strings.Join(ts, " "), forms
+}
+
+// clozeCommand asks user to fill in saved words missing from usage examples.
+type clozeCommand struct {
+	name string
+	// Word that is being asked, empty if none.
+	word string
+	// Form of the word used in the example.
+	form string
+	// When the word was asked.
+	shown time.Time
+}
+
+// Make sure all fields are Public, otherwise encoding will not work
+type clozeCommandSerialized struct {
+	Word         string
+	Form         string
+	ShownSeconds int64
+}
+
+func (c *clozeCommand) Serialize() *SerializedCommand {
+	cs := &clozeCommandSerialized{
+		Word:         c.word,
+		Form:         c.form,
+		ShownSeconds: c.shown.Unix(),
+	}
+	b, err := json.Marshal(cs)
+	if err != nil {
+		log.Printf("INTERNAL ERROR: Couldn't serialize %v: %v", cs, err)
+	}
+	return &SerializedCommand{
+		Name: c.name,
+		Data: b,
+	}
+}
+
+func (c *clozeCommand) Init(s *SerializedCommand) error {
+	cs := &clozeCommandSerialized{}
+	if err := json.Unmarshal(s.Data, cs); err != nil {
+		return fmt.Errorf("Unmarshal(%s): %w", s.Data, err)
+	}
+	c.word = cs.Word
+	c.form = cs.Form
+	c.shown = time.Unix(cs.ShownSeconds, 0)
+	return nil
+}
+
+func (c *clozeCommand) OnCommand(s *State, m *Message) (Command, error) {
+	return c.askNext(s, m.Chat.Id)
+}
+
+// example finds a usage example of the word with the word blanked out.
+func (c *clozeCommand) example(s *State, word string, settings *Settings) (text, form string, err error) {
+	tokenizer := s.Settings.Languages.Tokenizer(settings.InputLanguage)
+	forms, err := s.Usage.Forms(word, settings.InputLanguageISO639_3, tokenizer)
+	if err != nil {
+		return "", "", err
+	}
+	for _, f := range forms {
+		ex, err := s.Usage.FetchExamples(f, settings.InputLanguageISO639_3, settings.Translations())
+		if err != nil {
+			return "", "", err
+		}
+		for _, e := range ex {
+			t, fs := tokenizer.blankWord(e.Text, word)
+			if len(fs) == 0 {
+				continue
+			}
+			for _, tr := range e.Translations {
+				t += "\n  " + tr
+			}
+			return t, fs[0], nil
+		}
+	}
+	return "", "", nil
+}
+
+// askNext sends a usage example of the next due word which has one.
+func (c *clozeCommand) askNext(s *State, chatID int64) (Command, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return nil, err
+	}
+	// Daily limits and order apply like to the other practice modes.
+	scope, err := s.scope(chatID)
+	if err != nil {
+		return nil, err
+	}
+	words, err := s.Repetitions.OrderedDueWords(chatID, Reverse, scope)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range words {
+		text, form, err := c.example(s, w, settings)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving usage example of %q: %w", w, err)
+		}
+		if text == "" {
+			continue
+		}
+		if err := s.Telegram.SendTextMessage(chatID, text+"\n\nType the missing word."); err != nil {
+			return nil, err
+		}
+		c.word = w
+		c.form = form
+		c.shown = time.Now()
+		return c, nil
+	}
+	return nil, s.Telegram.SendTextMessage(chatID, "No more rows with usage examples to practice; exiting practice mode.")
+}
+
+func (c *clozeCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	if c.word == "" {
+		return nil, fmt.Errorf("INTERNAL ERROR: cloze practice without a word")
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return nil, err
+	}
+	// Dictionary form of the word is accepted too.
+	r := CheckSpelling(c.form, m.Text, settings.IgnoreDiacritics)
+	if r.Grade != GradeGood {
+		if w := CheckSpelling(c.word, m.Text, settings.IgnoreDiacritics); w.Grade > r.Grade {
+			r = w
+		}
+	}
+	if err := s.answer(chatID, c.word, Reverse, r.Grade, c.shown); err != nil {
+		return nil, err
+	}
+	correct := fmt.Sprintf("%q", c.form)
+	if !strings.EqualFold(c.form, c.word) {
+		correct += fmt.Sprintf(" (%q)", c.word)
+	}
+	var msg string
+	switch r.Grade {
+	case GradeGood:
+		msg = "Correct: " + correct
+	case GradeHard:
+		msg = fmt.Sprintf("Almost: %s\nCorrect: %s", r.Diff, correct)
+	default:
+		msg = "Wrong. Correct: " + correct
+	}
+	if err := s.Telegram.SendTextMessage(chatID, msg); err != nil {
+		return nil, err
+	}
+	return c.askNext(s, chatID)
+}
+
+func ClozeCommandFactory() CommandFactory {
+	return func(name string) Command {
+		return &clozeCommand{name: name}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBlankWord(t *testing.T) {
+	for _, tc := range []struct {
+		sentence, word string
+		want           string
+		wantForms      []string
+	}{
+		{"fekete kutya", "fekete", "_____ kutya", []string{"fekete"}},
+		{"Fekete kutya.", "fekete", "_____ kutya.", []string{"Fekete"}},
+		{"Látom a kutyát!", "kutya", "Látom a _____!", []string{"kutyát"}},
+		{"(Kutyák) és macskák", "kutya", "(_____) és macskák", []string{"Kutyák"}},
+		{"fehér fal", "fekete", "fehér fal", nil},
+		// Too long suffix is a different word.
+		{"feketerigó", "fekete", "feketerigó", nil},
+	} {
+		got, forms := defaultTokenizer.blankWord(tc.sentence, tc.word)
+		if got != tc.want || !reflect.DeepEqual(forms, tc.wantForms) {
+			t.Errorf("blankWord(%q, %q) = %q, %q; want %q, %q", tc.sentence, tc.word, got, forms, tc.want, tc.wantForms)
+		}
+	}
+}
+
+func TestUsageFetcherForms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloze")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	uf, err := NewUsageFetcher(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uf.db.Exec(usageSQL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uf.db.Exec(`
+		INSERT INTO Sentences(id, lang, text) VALUES
+			(10, "hun", "Feketét látok.");
+		INSERT INTO Words(word, lang, sentence_id) VALUES
+			("feketét", "hun", 10),
+			("látok", "hun", 10);`); err != nil {
+		t.Fatal(err)
+	}
+	got, err := uf.Forms("fekete", "hun", defaultTokenizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"fekete", "feketét"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Forms(fekete): got %q; want %q", got, want)
+	}
+}
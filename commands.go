@@ -15,7 +15,9 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -31,57 +33,18 @@ import (
 type PracticeType int
 
 const (
-	// answer should match exact word
-	// FIXME: FIXME: FIXME: FIXME: This doesn't work!!!!!!!!
-	//  cannot save obfuscated - cannot check.
-	//  cannot save clear - cannot extract raw from obfuscated.
-	//  this need fixing - make sure repetition_test passes.
+	// answer should match the word, see /spell
 	PracticeWordSpelling PracticeType = iota
 	// answer of the form know/don't know
 	PracticeKnowledge
+	// answer is one of several definitions, see /quiz
+	PracticeQuiz
+	// answer is the word missing from a usage example, see /cloze
+	PracticeCloze
 )
 
 const UsePractice = PracticeKnowledge
 
-var (
-	SupportedInputLanguages = map[string]Settings{
-		"Hungarian": Settings{
-			InputLanguage:         "Hungarian",
-			InputLanguageISO639_3: "hun",
-			TranslationLanguages: map[string]bool{
-				"eng": true,
-				"rus": true,
-				"ukr": true,
-			},
-		},
-		"English": Settings{
-			InputLanguage:         "English",
-			InputLanguageISO639_3: "eng",
-			TranslationLanguages: map[string]bool{
-				"rus": true,
-				"ukr": true,
-			},
-		},
-		"German": Settings{
-			InputLanguage:         "German",
-			InputLanguageISO639_3: "deu",
-			TranslationLanguages: map[string]bool{
-				"eng": true,
-				"rus": true,
-				"ukr": true,
-			},
-		},
-	}
-	TimeZones = func() map[string]bool {
-		timeZones := make(map[string]bool)
-		for i := -12; i < 12; i++ {
-			timeZones[fmt.Sprintf("UTC%+d", i)] = true
-		}
-		timeZones["UTC"] = true
-		return timeZones
-	}()
-)
-
 type CallbackAction int
 
 const (
@@ -89,6 +52,27 @@ const (
 	PracticeKnowAction
 	PracticeDontKnowAction
 	PracticeDontKnowActionNoPractice
+	PracticeGradeAction
+	PracticeQuizAction
+	PracticeReverseGradeAction
+	TagAction
+	EditAction
+	KeepDefinitionAction
+	ReplaceDefinitionAction
+	AppendDefinitionAction
+	SuspendAction
+	KeepLeechAction
+	PracticeSuspendAction
+	PracticeBuryAction
+	UnsuspendAction
+	PracticeNowAction
+	SnoozeHourAction
+	SnoozeTomorrowAction
+	MuteWeekAction
+	UnsnoozeAction
+	PracticeReverseSuspendAction
+	PracticeReverseBuryAction
+	ResetProgressAction
 )
 
 // Make sure all fields are Public, otherwise encoding will not work
@@ -100,6 +84,9 @@ type CallbackInfo struct {
 	// One of below is set depending on the action.
 	Word    string
 	Setting string
+	Grade   Grade `json:",omitempty"`
+	// Option chosen in the quiz, starting from 1.
+	Option int `json:",omitempty"`
 }
 
 // FIXME: Should return an error?
@@ -133,6 +120,12 @@ type CommanderOptions struct {
 	ip       string
 	push     bool
 	stages   []time.Duration
+	// Scheduler used for users who haven't chosen one. Legacy is used if
+	// empty.
+	scheduler string
+	// JSON file with supported input languages. Built-in ones are used if
+	// empty.
+	languagesPath string
 }
 
 func escapeMarkdown(s string) string {
@@ -181,20 +174,45 @@ func NewCommander(tm *Telegram, opts *CommanderOptions) (*Commander, error) {
 	if err != nil {
 		return nil, fmt.Errorf("creating settings config: %w", err)
 	}
+	sc.Languages, err = LoadLanguages(opts.languagesPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading languages: %w", err)
+	}
 	d := &Definer{
-		usage: uf,
-		cache: cache,
-		http:  hc,
+		usage:     uf,
+		cache:     cache,
+		http:      hc,
+		languages: sc.Languages,
 	}
 	r, err := NewRepetition(opts.dbPath, opts.stages)
 	if err != nil {
 		return nil, err
 	}
+	if opts.scheduler != "" {
+		if err := ValidateScheduler(opts.scheduler); err != nil {
+			return nil, err
+		}
+		r.defaultScheduler = opts.scheduler
+	}
+	cs, err := NewCommandStore(opts.dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("creating command store: %w", err)
+	}
 	c := &Clients{
 		Telegram:    tm,
 		Definer:     d,
 		Repetitions: r,
 		Settings:    sc,
+		Commands:    cs,
+		Usage:       uf,
+	}
+	db, err := sql.Open("sqlite3", opts.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	c.Reminders, err = NewReminder(c, db)
+	if err != nil {
+		return nil, fmt.Errorf("creating reminder: %w", err)
 	}
 
 	// Make sure that telegram client is setup correctly
@@ -275,7 +293,18 @@ func (c *Commander) WebhookCallback(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (c *Commander) StartPush(opts *CommanderOptions) error {
+// StartReminders starts reminding users to practice in the background until
+// ctx is done.
+func (c *Commander) StartReminders(ctx context.Context) {
+	go func() {
+		t := time.NewTicker(reminderCheckPeriod)
+		defer t.Stop()
+		c.Reminders.Loop(t.C, ctx.Done())
+		log.Printf("Reminders stopped")
+	}()
+}
+
+func (c *Commander) StartPush(ctx context.Context, opts *CommanderOptions) error {
 	addr := fmt.Sprintf("https://%s:%d/%s", opts.ip, opts.port, BotToken)
 	if err := c.Telegram.SetWebhook(addr, opts.certPath); err != nil {
 		return err
@@ -300,13 +329,22 @@ func (c *Commander) StartPush(opts *CommanderOptions) error {
 		TLSConfig:    cfg,
 		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
 	}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Printf("ERROR: shutting down server: %v", err)
+		}
+	}()
 	log.Printf("Starting serving on %s", addr)
-	return srv.ListenAndServeTLS(opts.certPath, opts.keyPath)
+	if err := srv.ListenAndServeTLS(opts.certPath, opts.keyPath); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
 
 // TODO: Accept time.Ticker channel -> Will give an ability to inline
 // PollAndProcess and test Start in addition to the rest.
-func (c *Commander) StartPoll() error {
+func (c *Commander) StartPoll(ctx context.Context) error {
 	// Reset webhook, otherwise getUpdates would not work!
 	if err := c.Telegram.SetWebhook("", ""); err != nil {
 		return err
@@ -316,6 +354,10 @@ func (c *Commander) StartPoll() error {
 		if err := c.PollAndProcess(); err != nil {
 			return err
 		}
-		time.Sleep(time.Second * 3)
+		select {
+		case <-time.After(time.Second * 3):
+		case <-ctx.Done():
+			return nil
+		}
 	}
 }
@@ -25,7 +25,9 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Callback interface {
@@ -38,13 +40,58 @@ type State struct {
 	*Clients
 }
 
-// TODO:
+// LoadCommand returns the last command saved for the chat, nil if there is
+// none.
 func (s *State) LoadCommand(chatID int64) (*SerializedCommand, error) {
-	return nil, nil
+	return s.Commands.Load(chatID)
+}
+
+// SaveCommand persists the command, so that it can be restored after restart.
+// nil command resets the chat to the default command.
+func (s *State) SaveCommand(chatID int64, c *SerializedCommand) error {
+	return s.Commands.Save(chatID, c)
+}
+
+// scheduler returns the scheduler chosen by the user and its name.
+func (s *State) scheduler(chatID int64) (string, Scheduler, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return "", nil, err
+	}
+	name, sched := s.Repetitions.Scheduler(settings.Scheduler)
+	return name, sched, nil
+}
+
+// scope returns the words which the user has chosen to practice within the
+// daily limits, and the order of practicing them.
+func (s *State) scope(chatID int64) (Scope, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return Scope{}, err
+	}
+	now := time.Now()
+	scope := settings.PracticeScope()
+	scope.Order = settings.PracticeOrder()
+	// Random order stays the same during the day.
+	scope.Order.Seed = startOfDay(now, settings.Location()).Unix()
+	return limitScope(s.Repetitions, chatID, settings, scope, now)
 }
 
-// TODO:
-func (s *State) SaveCommand(chatID int64, _ *SerializedCommand) error {
+// answer reschedules the word practiced in the direction with the scheduler
+// chosen by the user. shown is the time when the card was shown, zero if
+// unknown.
+func (s *State) answer(chatID int64, word string, dir Direction, g Grade, shown time.Time) error {
+	_, sched, err := s.scheduler(chatID)
+	if err != nil {
+		return err
+	}
+	c, err := s.Repetitions.AnswerGrade(chatID, word, dir, g, sched, shown)
+	if err != nil {
+		return err
+	}
+	if g == GradeAgain && IsLeech(c.Lapses) {
+		return leechReply(s, chatID, word, c.Lapses)
+	}
 	return nil
 }
 
@@ -107,6 +154,9 @@ func (b *Bot) Update(u *Update) (err error) {
 	if u.CallbackQuery != nil {
 		for _, c := range CommandsTemplate.Callbacks {
 			if c.Match(b.state, u.CallbackQuery) {
+				// Callbacks might change the command through the state, so
+				// it will be loaded again.
+				delete(b.command, chatId)
 				return c.Call(b.state, u.CallbackQuery)
 			}
 		}
@@ -121,7 +171,8 @@ func (b *Bot) Update(u *Update) (err error) {
 	// Update is a Message.
 	msg := u.Message.Text
 	for n, f := range CommandsTemplate.Commands {
-		if msg == n {
+		// Some commands accept arguments, e.g. "/deck use German".
+		if msg == n || strings.HasPrefix(msg, n+" ") {
 			cmd := f(n)
 			cmd, err = cmd.OnCommand(b.state, u.Message)
 			if err != nil {
@@ -199,10 +250,14 @@ func (u UserError) Surface(s *State) error {
 }
 
 type question struct {
-	name     string
-	ask      func(s *State, chatID int64) error
+	name string
+	// qs contains answers to the questions asked before.
+	ask      func(s *State, chatID int64, qs []*question) error
 	validate func(*State, *Message) error
-	answer   string
+	// parse returns the answer from the validated message, the message text is
+	// used if it isn't set.
+	parse  func(*State, *Message) (string, error)
+	answer string
 }
 
 type multiQuestionCommand struct {
@@ -219,17 +274,25 @@ type multiQuestionCommand struct {
 
 func MultiQuestionCommandFactory(questions []*question, save func(state *State, chatID int64, questions []*question) error) CommandFactory {
 	return func(name string) Command {
+		// Each command gets its own copy of questions, otherwise answers would
+		// be shared between the chats.
+		qs := make([]*question, len(questions))
+		for i, q := range questions {
+			qc := *q
+			qs[i] = &qc
+		}
 		return &multiQuestionCommand{
 			name:      name,
-			questions: questions,
+			questions: qs,
 			save:      save,
 		}
 	}
 }
 
+// Make sure all fields are Public, otherwise encoding will not work
 type multiQuestionCommandSerialized struct {
-	answers      map[string]string
-	lastQuestion string
+	Answers      map[string]string
+	LastQuestion string
 }
 
 func (c *multiQuestionCommand) Serialize() *SerializedCommand {
@@ -240,8 +303,8 @@ func (c *multiQuestionCommand) Serialize() *SerializedCommand {
 		a[q.name] = q.answer
 	}
 	cs := &multiQuestionCommandSerialized{
-		answers:      a,
-		lastQuestion: c.lastQuestion,
+		Answers:      a,
+		LastQuestion: c.lastQuestion,
 	}
 	b, err := json.Marshal(cs)
 	if err != nil {
@@ -259,23 +322,16 @@ func (c *multiQuestionCommand) Init(s *SerializedCommand) error {
 		return fmt.Errorf("Unmarshal(%s): %w", s.Data, err)
 	}
 	for _, q := range c.questions {
-		q.answer = cs.answers[q.name]
+		q.answer = cs.Answers[q.name]
 	}
-	c.lastQuestion = cs.lastQuestion
+	c.lastQuestion = cs.LastQuestion
 	return nil
 }
 
 func (c *multiQuestionCommand) OnCommand(s *State, m *Message) (Command, error) {
-	chatID := m.Chat.Id
-	if len(c.questions) == 0 {
-		// With to questions, no reason to have this command process messages.
-		return nil, c.save(s, chatID, c.questions)
-	}
-	if err := c.questions[0].ask(s, chatID); err != nil {
-		return nil, err
-	}
-	c.lastQuestion = c.questions[0].name
-	return c, nil
+	// With no questions, there is no reason to have this command process
+	// messages, askNext will save right away.
+	return c.askNext(s, m.Chat.Id)
 }
 
 func (c *multiQuestionCommand) ProcessMessage(s *State, m *Message) (Command, error) {
@@ -295,7 +351,19 @@ func (c *multiQuestionCommand) ProcessMessage(s *State, m *Message) (Command, er
 		return c, err
 	}
 	q.answer = m.Text
+	if q.parse != nil {
+		a, err := q.parse(s, m)
+		if err != nil {
+			return c, err
+		}
+		q.answer = a
+	}
+	return c.askNext(s, m.Chat.Id)
+}
 
+// askNext asks the first unanswered question or saves once all of them are
+// answered.
+func (c *multiQuestionCommand) askNext(s *State, chatID int64) (Command, error) {
 	var next *question = nil
 	for _, qe := range c.questions {
 		if qe.answer == "" {
@@ -304,12 +372,12 @@ func (c *multiQuestionCommand) ProcessMessage(s *State, m *Message) (Command, er
 		}
 	}
 	if next == nil {
-		err := c.save(s, m.Chat.Id, c.questions)
+		err := c.save(s, chatID, c.questions)
 		// After all questions have been answered there is no point in
 		// keeping trying to save, even if it fails with UserError.
 		return nil, err
 	}
-	if err := next.ask(s, m.Chat.Id); err != nil {
+	if err := next.ask(s, chatID, c.questions); err != nil {
 		// ask should never fail with user error.
 		return nil, err
 	}
@@ -325,6 +393,37 @@ func ReplyCommand(reply func(s *State, chatID int64) error) CommandFactory {
 	)
 }
 
+// argsCommand replies to the command with arguments, e.g. "/deck use German".
+type argsCommand struct {
+	name  string
+	reply func(s *State, chatID int64, args string) error
+}
+
+func (c *argsCommand) Serialize() *SerializedCommand {
+	return &SerializedCommand{Name: c.name}
+}
+
+func (c *argsCommand) Init(*SerializedCommand) error {
+	return nil
+}
+
+func (c *argsCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	return CommandsTemplate.DefaultCommand("").ProcessMessage(s, m)
+}
+
+func (c *argsCommand) OnCommand(s *State, m *Message) (Command, error) {
+	args := strings.TrimSpace(strings.TrimPrefix(m.Text, c.name))
+	return nil, c.reply(s, m.Chat.Id, args)
+}
+
+// ArgsCommand creates a command which replies to the command using arguments
+// passed after the command name.
+func ArgsCommand(reply func(s *State, chatID int64, args string) error) CommandFactory {
+	return func(name string) Command {
+		return &argsCommand{name: name, reply: reply}
+	}
+}
+
 func NewMessageReply(chatID int64, text string, callbacks []Callback) *MessageReply {
 	var ik []*InlineKeyboard
 	for _, c := range callbacks {
@@ -350,15 +449,61 @@ func practiceReply(s *State, chatID int64) error {
 	default:
 		panic(fmt.Sprintf("INTERNAL: Unimplemented practice type: %v", UsePractice))
 	}
-	word, err := s.Repetitions.RepeatWord(chatID)
+	scope, err := s.scope(chatID)
+	if err != nil {
+		return err
+	}
+	word, err := s.Repetitions.RepeatWord(chatID, Forward, scope)
 	if err == sql.ErrNoRows {
 		// FIXME: Make this user error instead.
-		return s.Telegram.SendTextMessage(chatID, "No more rows to practice; exiting practice mode.")
+		return doneReply(s, chatID, Forward, scope)
 	}
 	if err != nil {
 		return fmt.Errorf("retrieving word for repetition: %w", err)
 	}
-	return s.Telegram.SendMessage(NewMessageReply(chatID, word, []Callback{KnowCallback{word}, DontKnowCallback{word, true}}))
+	name, _, err := s.scheduler(chatID)
+	if err != nil {
+		return err
+	}
+	cs := []Callback{KnowCallback{word}, DontKnowCallback{word, true}}
+	if name != LegacyScheduler {
+		cs = nil
+		for _, g := range Grades {
+			cs = append(cs, GradeCallback{word, g})
+		}
+	}
+	cs = append(cs, BuryCallback{Word: word}, SuspendCallback{Word: word, Practice: true})
+	return s.Telegram.SendMessage(NewMessageReply(chatID, word, cs))
+}
+
+// reverseReply sends practice card with the definition of the word, so that
+// the word has to be recalled.
+func reverseReply(s *State, chatID int64) error {
+	scope, err := s.scope(chatID)
+	if err != nil {
+		return err
+	}
+	word, question, err := s.Repetitions.Repeat(chatID, Reverse, scope)
+	if err == sql.ErrNoRows {
+		return doneReply(s, chatID, Reverse, scope)
+	}
+	if err != nil {
+		return fmt.Errorf("retrieving word for repetition: %w", err)
+	}
+	name, _, err := s.scheduler(chatID)
+	if err != nil {
+		return err
+	}
+	gs := Grades
+	if name == LegacyScheduler {
+		gs = []Grade{GradeAgain, GradeGood}
+	}
+	var cs []Callback
+	for _, g := range gs {
+		cs = append(cs, ReverseGradeCallback{word, g})
+	}
+	cs = append(cs, BuryCallback{Word: word, Reverse: true}, SuspendCallback{Word: word, Practice: true, Reverse: true})
+	return s.Telegram.SendMessage(NewMessageReply(chatID, question+"\n\nRecall the word.", cs))
 }
 
 // settingsReply sends current settings and instructions on how to change them.
@@ -368,7 +513,7 @@ func settingsReply(state *State, chatID int64) error {
 		return err
 	}
 	var ls []string
-	for l, v := range s.TranslationLanguages {
+	for l, v := range s.Translations() {
 		if v {
 			ls = append(ls, fmt.Sprintf("%q", l))
 		}
@@ -379,6 +524,11 @@ func settingsReply(state *State, chatID int64) error {
 		cmds = append(cmds, "  "+k)
 	}
 	sort.Strings(cmds)
+	scheduler, _ := state.Repetitions.Scheduler(s.Scheduler)
+	snoozed, err := state.Reminders.SnoozedUntil(chatID)
+	if err != nil {
+		return err
+	}
 	msg := fmt.Sprintf(`
 Current settings:
 
@@ -386,13 +536,37 @@ Input language: %q
 Input language in ISO 639-3: %q
 Translation languages in ISO 639-3: %s
 Time Zone: %s
+Scheduler: %s
+Ignore diacritics in spelling: %t
+Deck for new words: %q
+Practiced words: %s
+Daily limits: %s
+Practice order: %s
+Reminders: %s
 
 To modify settings use one of the commands below:
 %s
-`, s.InputLanguage, s.InputLanguageISO639_3, strings.Join(ls, ","), s.TimeZone, strings.Join(cmds, "\n"))
+`, s.InputLanguage, s.InputLanguageISO639_3, strings.Join(ls, ","), s.TimeZone, scheduler, s.IgnoreDiacritics, s.ActiveDeck(), s.PracticeScope(), s.DailyLimits(), s.PracticeOrder(), s.RemindersString(snoozed, time.Now()), strings.Join(cmds, "\n"))
 	return state.Telegram.SendMessage(NewMessageReply(chatID, msg, nil))
 }
 
+// statsReply sends statistics of user's progress.
+func statsReply(state *State, chatID int64) error {
+	s, err := state.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	st, err := state.Repetitions.Stats(chatID, s.Location(), time.Now())
+	if err != nil {
+		return err
+	}
+	return state.Telegram.SendMessage(&MessageReply{
+		ChatId:    chatID,
+		Text:      st.Format(),
+		ParseMode: "MarkdownV2",
+	})
+}
+
 // This inteface is a bit redundant. We need it though to avoid initialization
 // loop with SettingsCommands depending on settingsReply and settingsReply
 // depending on SettingsCommands.
@@ -402,13 +576,25 @@ type SimpleQuestionCommand interface {
 	Save(_ *State, chatID int64, answer string) error
 }
 
+// answerParser is implemented by SimpleQuestionCommand when the answer isn't
+// just the text of the message.
+type answerParser interface {
+	Parse(*State, *Message) (string, error)
+}
+
 func SimpleQuestionCommandFactory(c SimpleQuestionCommand) CommandFactory {
+	q := &question{
+		name: "question",
+		ask: func(s *State, chatID int64, _ []*question) error {
+			return c.Ask(s, chatID)
+		},
+		validate: c.Validate,
+	}
+	if p, ok := c.(answerParser); ok {
+		q.parse = p.Parse
+	}
 	return MultiQuestionCommandFactory(
-		[]*question{{
-			name:     "question",
-			ask:      c.Ask,
-			validate: c.Validate,
-		}},
+		[]*question{q},
 		func(s *State, chatID int64, questions []*question) error {
 			return c.Save(s, chatID, questions[0].answer)
 		},
@@ -422,7 +608,7 @@ type SimpleSettingCommand struct {
 }
 
 func (c *SimpleSettingCommand) Ask(s *State, chatID int64) error {
-	return askQuestion(c.question)(s, chatID)
+	return s.Telegram.SendTextMessage(chatID, c.question)
 }
 
 func (c *SimpleSettingCommand) Validate(s *State, m *Message) error {
@@ -439,8 +625,8 @@ func (c *SimpleSettingCommand) Save(s *State, chatID int64, answer string) error
 	return settingsReply(s, chatID)
 }
 
-func askQuestion(q string) func(s *State, chatID int64) error {
-	return func(s *State, chatID int64) error {
+func askQuestion(q string) func(s *State, chatID int64, _ []*question) error {
+	return func(s *State, chatID int64, _ []*question) error {
 		return s.Telegram.SendTextMessage(chatID, q)
 	}
 }
@@ -470,35 +656,117 @@ func AddCommandFactory() CommandFactory {
 					return fmt.Errorf("unexpected question in save: %v", q)
 				}
 			}
-			if err := s.Repetitions.Save(chatID, front, back); err != nil {
+			settings, err := s.Settings.Get(chatID)
+			if err != nil {
 				return err
 			}
-			return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Added %q for learning!", front))
+			err = s.Repetitions.Save(chatID, settings.ActiveDeck(), front, back)
+			if err == ErrWordExists {
+				if err := s.Telegram.SendTextMessage(chatID, fmt.Sprintf("%q is already saved for learning. What should be done with the new back of the card?", front)); err != nil {
+					return err
+				}
+				return s.Telegram.SendMessage(NewMessageReply(chatID, back, MergeCallbacks(front)))
+			}
+			if err != nil {
+				return err
+			}
+			return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Added %q for learning to deck %q!", front, settings.ActiveDeck()))
 		},
 	)
 }
 
+// checkSaved returns user error if the word isn't saved for learning.
+func checkSaved(s *State, chatID int64, word string) error {
+	e, err := s.Repetitions.Exists(chatID, word)
+	if err != nil {
+		return err
+	}
+	if !e {
+		return UserError{ChatID: chatID, Err: fmt.Errorf("Word %q isn't saved for learning!", word)}
+	}
+	return nil
+}
+
+func validateSaved(s *State, m *Message) error {
+	return checkSaved(s, m.Chat.Id, m.Text)
+}
+
 func DeleteCommandFactory() CommandFactory {
 	return MultiQuestionCommandFactory(
 		[]*question{{
-			name: "word",
-			ask:  askQuestion("Enter the word you want to delete from learning!"),
-			validate: func(s *State, m *Message) error {
-				e, err := s.Repetitions.Exists(m.Chat.Id, m.Text)
+			name:     "word",
+			ask:      askQuestion("Enter the word you want to delete from learning!"),
+			validate: validateSaved,
+		}},
+		func(s *State, chatID int64, qs []*question) error {
+			if err := s.Repetitions.Delete(chatID, qs[0].answer); err != nil {
+				return err
+			}
+			return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Deleted %q!", qs[0].answer))
+		},
+	)
+}
+
+// Answer to /edit questions to keep the current front or back.
+const keepAnswer = "-"
+
+func EditCommandFactory() CommandFactory {
+	validateNotEmpty := func(s *State, m *Message) error {
+		if strings.TrimSpace(m.Text) == "" {
+			return UserError{ChatID: m.Chat.Id, Err: fmt.Errorf("Answer can't be empty. Please try again.")}
+		}
+		return nil
+	}
+	return MultiQuestionCommandFactory(
+		// Edit button of the card answers the first question, see EditCallback.
+		[]*question{{
+			name:     "word",
+			ask:      askQuestion("Enter the word you want to edit!"),
+			validate: validateSaved,
+		}, {
+			name: "front",
+			ask: func(s *State, chatID int64, qs []*question) error {
+				return s.Telegram.SendTextMessage(chatID, fmt.Sprintf(
+					"Enter new front of the card or %s to keep %q.", keepAnswer, qs[0].answer))
+			},
+			validate: validateNotEmpty,
+		}, {
+			name: "back",
+			ask: func(s *State, chatID int64, qs []*question) error {
+				def, err := s.Repetitions.GetDefinition(chatID, qs[0].answer)
 				if err != nil {
 					return err
 				}
-				if !e {
-					return UserError{ChatID: m.Chat.Id, Err: fmt.Errorf("Word %q isn't saved for learning!", m.Text)}
-				}
-				return nil
+				return s.Telegram.SendTextMessage(chatID, fmt.Sprintf(
+					"Current back of the card:\n\n%s\n\nEnter new back of the card or %s to keep it.", def, keepAnswer))
 			},
+			validate: validateNotEmpty,
 		}},
 		func(s *State, chatID int64, qs []*question) error {
-			if err := s.Repetitions.Delete(chatID, qs[0].answer); err != nil {
+			word, front, back := qs[0].answer, qs[1].answer, qs[2].answer
+			if front == keepAnswer {
+				front = word
+			}
+			if back == keepAnswer {
+				def, err := s.Repetitions.GetDefinition(chatID, word)
+				if err != nil {
+					return err
+				}
+				back = def
+			}
+			if front != word {
+				e, err := s.Repetitions.Exists(chatID, front)
+				if err != nil {
+					return err
+				}
+				if e {
+					return UserError{ChatID: chatID, Err: fmt.Errorf("Word %q is already saved for learning!", front)}
+				}
+			}
+			if err := s.Repetitions.Edit(chatID, word, front, back); err != nil {
 				return err
 			}
-			return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Deleted %q!", qs[0].answer))
+			return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Edited %q!", front))
 		},
 	)
 }
@@ -514,6 +782,9 @@ func (defaultCommand) Init(*SerializedCommand) error {
 func (defaultCommand) ProcessMessage(s *State, m *Message) (Command, error) {
 	chatID := m.Chat.Id
 
+	if m.Document != nil {
+		return nil, UserError{ChatID: chatID, Err: fmt.Errorf("Use /import to import words from files.")}
+	}
 	if len(strings.Split(m.Text, " ")) > 1 {
 		return nil, UserError{ChatID: chatID, Err: fmt.Errorf("For now this bot doesn't work with expressions. Try entering a single work without spaces.")}
 	}
@@ -522,7 +793,7 @@ func (defaultCommand) ProcessMessage(s *State, m *Message) (Command, error) {
 	if err == nil {
 		return nil, s.Telegram.SendMessage(NewMessageReply(
 			m.Chat.Id, def,
-			[]Callback{ResetProgressCallback{m.Text}}))
+			[]Callback{ResetProgressCallback{m.Text}, TagCallback{m.Text}}))
 	}
 	if err != sql.ErrNoRows {
 		log.Printf("ERROR: Repetitions(%d, %s): %v", m.Chat.Id, m.Text, err)
@@ -534,7 +805,7 @@ func (defaultCommand) ProcessMessage(s *State, m *Message) (Command, error) {
 	ds, err := s.Definer.Define(m.Text, settings)
 	if err != nil {
 		// TODO: Might be good to post debug logs to the reply in the debug mode.
-		log.Printf("Error fetching the definition: %w", err)
+		log.Printf("Error fetching the definition: %v", err)
 		// TODO: Add search url to the reply?
 		return nil, UserError{
 			ChatID: m.Chat.Id,
@@ -588,30 +859,120 @@ func textReply(text string) CommandFactory {
 // SettingsCommands contains all settings-related commands. They are bundled
 // together for convenience to have everything in one place.
 var SettingsCommands = map[string]CommandFactory{
-	"/language": SimpleQuestionCommandFactory(&SimpleSettingCommand{
-		question: func() string {
-			var ls []string
-			for l, _ := range SupportedInputLanguages {
-				ls = append(ls, fmt.Sprintf("%q", l))
+	"/language": SimpleQuestionCommandFactory(LanguageCommand{}),
+	"/timezone": SimpleQuestionCommandFactory(TimeZoneCommand{}),
+	"/scheduler": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: fmt.Sprintf("Choose how cards are scheduled for practice. Supported are %s.\n"+
+			"legacy - fixed intervals with Know/Don't know answers.\n"+
+			"sm2 - SuperMemo 2, intervals adapt to how hard each card is.\n"+
+			"fsrs - Free Spaced Repetition Scheduler, models how well you remember each card.",
+			strings.Join(SchedulerNames, ", ")),
+		validate: func(s *State, answer string) error {
+			return ValidateScheduler(answer)
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			return s.Settings.SetScheduler(chatID, answer)
+		},
+	}),
+	"/dailylimits": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "Enter maximum number of new words and reviews per day, e.g. \"20 200\". 0 means no limit.",
+		validate: func(s *State, answer string) error {
+			_, err := ParseDailyLimits(answer)
+			return err
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			l, err := ParseDailyLimits(answer)
+			if err != nil {
+				return err
+			}
+			return s.Settings.SetDailyLimits(chatID, l)
+		},
+	}),
+	"/order": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: fmt.Sprintf("Choose in which order due words are practiced. Supported are %s.\n"+
+			"overdue - the most overdue words first.\n"+
+			"stage - the least learned words first.\n"+
+			"random - random order, which stays the same during the day.\n"+
+			"interleaved N - a new word after every N reviews, e.g. \"interleaved 5\".",
+			strings.Join(OrderPolicies, ", ")),
+		validate: func(s *State, answer string) error {
+			_, err := ParseOrder(answer)
+			return err
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			o, err := ParseOrder(answer)
+			if err != nil {
+				return err
+			}
+			return s.Settings.SetOrder(chatID, o.String())
+		},
+	}),
+	"/reminderwindow": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "Enter the time of the day when reminders can be sent in your time zone, e.g. \"08:00-21:00\". " +
+			"It can cross midnight, e.g. \"20:00-01:00\", or be \"all\" for the whole day.",
+		validate: func(s *State, answer string) error {
+			_, _, err := ParseReminderWindow(answer)
+			return err
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			from, to, err := ParseReminderWindow(answer)
+			if err != nil {
+				return err
+			}
+			if err := s.Settings.SetReminderWindow(chatID, from, to); err != nil {
+				return err
 			}
-			sort.Strings(ls)
-			return fmt.Sprintf("Enter input language of your choice. Supported are %s",
-				strings.Join(ls, ","))
-		}(),
+			// Changing any of the reminder settings ends the snooze, so
+			// that muted reminders can be turned on again.
+			return s.Reminders.Unsnooze(chatID)
+		},
+	}),
+	"/reminderfrequency": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: fmt.Sprintf("Enter how many reminders per day should be sent, from 1 to %d. They are spread evenly across the /reminderwindow.", maxRemindersPerDay),
 		validate: func(s *State, answer string) error {
-			return s.Settings.ValidateLanguage(answer)
+			if n, err := strconv.Atoi(answer); err != nil || n < 1 || n > maxRemindersPerDay {
+				return fmt.Errorf("%q isn't a number from 1 to %d", answer, maxRemindersPerDay)
+			}
+			return nil
 		},
 		save: func(s *State, chatID int64, answer string) error {
-			return s.Settings.SetLanguage(chatID, answer)
+			n, err := strconv.Atoi(answer)
+			if err != nil {
+				return err
+			}
+			if err := s.Settings.SetRemindersPerDay(chatID, n); err != nil {
+				return err
+			}
+			return s.Reminders.Unsnooze(chatID)
 		},
 	}),
-	"/timezone": SimpleQuestionCommandFactory(&SimpleSettingCommand{
-		question: "Input your timezone in one of the formats: UTC, UTC+X or UTC-X.",
+	"/reminderdays": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "Enter days of the week when reminders should be sent, e.g. \"Mon Wed Fri\", or \"all\" for every day.",
 		validate: func(s *State, answer string) error {
-			return s.Settings.ValidateTimeZone(answer)
+			_, err := ParseWeekdays(answer)
+			return err
+		},
+		save: func(s *State, chatID int64, answer string) error {
+			ds, err := ParseWeekdays(answer)
+			if err != nil {
+				return err
+			}
+			if err := s.Settings.SetRemindWeekdays(chatID, ds); err != nil {
+				return err
+			}
+			return s.Reminders.Unsnooze(chatID)
+		},
+	}),
+	"/diacritics": SimpleQuestionCommandFactory(&SimpleSettingCommand{
+		question: "Should spelling practice accept answers without diacritics (e.g. \"o\" for \"ő\")? Answer yes or no.",
+		validate: func(s *State, answer string) error {
+			if answer != "yes" && answer != "no" {
+				return fmt.Errorf("answer should be yes or no")
+			}
+			return nil
 		},
 		save: func(s *State, chatID int64, answer string) error {
-			return s.Settings.SetTimeZone(chatID, answer)
+			return s.Settings.SetIgnoreDiacritics(chatID, answer == "yes")
 		},
 	}),
 }
@@ -637,17 +998,41 @@ var CommandsTemplate = struct {
 					"so far. " +
 					"All sentences and translations are from Tatoeba's (https://tatoeba.org) " +
 					"dataset, released under a CC-BY 2.0 FR."),
-			"/stop":     textReply("Stopped. Input the word to get it's definition."),
-			"/practice": ReplyCommand(practiceReply),
-			"/settings": ReplyCommand(settingsReply),
-			"/add":      AddCommandFactory(),
-			"/delete":   DeleteCommandFactory(),
+			"/stop":      textReply("Stopped. Input the word to get it's definition."),
+			"/practice":  ArgsCommand(practiceCommandReply),
+			"/spell":     SpellingCommandFactory(),
+			"/quiz":      QuizCommandFactory(),
+			"/reverse":   ReplyCommand(reverseReply),
+			"/cloze":     ClozeCommandFactory(),
+			"/deck":      ArgsCommand(deckReply),
+			"/tag":       TagCommandFactory(),
+			"/import":    ImportCommandFactory(),
+			"/export":    ArgsCommand(exportReply),
+			"/settings":  ReplyCommand(settingsReply),
+			"/stats":     ReplyCommand(statsReply),
+			"/add":       AddCommandFactory(),
+			"/delete":    DeleteCommandFactory(),
+			"/edit":      EditCommandFactory(),
+			"/suspended": ReplyCommand(suspendedReply),
 		},
 		SettingsCommands,
 	),
 	Callbacks: []Callback{
 		KnowCallback{},
 		DontKnowCallback{},
+		GradeCallback{},
+		ResetProgressCallback{},
+		QuizCallback{},
+		ReverseGradeCallback{},
+		TagCallback{},
+		EditCallback{},
+		MergeCallback{},
+		SuspendCallback{},
+		KeepLeechCallback{},
+		BuryCallback{},
+		UnsuspendCallback{},
+		PracticeNowCallback{},
+		SnoozeCallback{},
 		LearnCallback{},
 	},
 	DefaultCommand: func(string) Command { return defaultCommand{} },
@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// CommandStore persists the command each chat is currently in, so that
+// conversations survive bot restarts.
+type CommandStore struct {
+	db *sql.DB
+}
+
+func NewCommandStore(dbPath string) (*CommandStore, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS Commands (
+			chat_id INTEGER PRIMARY KEY,
+			name STRING,
+			data BLOB -- command specific serialized state
+		);`); err != nil {
+		return nil, err
+	}
+	return &CommandStore{db}, nil
+}
+
+// Load returns the command saved for the chat or nil if there is none.
+func (c *CommandStore) Load(chatID int64) (*SerializedCommand, error) {
+	row := c.db.QueryRow(`
+		SELECT name, data
+		FROM Commands
+		WHERE chat_id = $0`,
+		chatID)
+	s := &SerializedCommand{}
+	if err := row.Scan(&s.Name, &s.Data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("INTERNAL: retrieving command for chat id %d: %w", chatID, err)
+	}
+	return s, nil
+}
+
+// Save stores the command for the chat. Saving nil command removes it.
+func (c *CommandStore) Save(chatID int64, s *SerializedCommand) error {
+	var err error
+	if s == nil {
+		_, err = c.db.Exec(`
+			DELETE
+			FROM Commands
+			WHERE chat_id = $0`,
+			chatID)
+	} else {
+		_, err = c.db.Exec(`
+			INSERT OR REPLACE INTO Commands(chat_id, name, data) VALUES
+			($0, $1, $2);`,
+			chatID, s.Name, s.Data)
+	}
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed saving command for chat id %d: %w", chatID, err)
+	}
+	return nil
+}
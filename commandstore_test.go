@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCommandStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "commandstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	db := filepath.Join(dir, "tmpdb")
+	cs, err := NewCommandStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const chatID int64 = 1
+	if s, err := cs.Load(chatID); err != nil || s != nil {
+		t.Errorf("cs.Load: %v, %v want nil, nil", s, err)
+	}
+
+	// Simulate user being in the middle of /add.
+	cmd := AddCommandFactory()("/add").(*multiQuestionCommand)
+	cmd.questions[0].answer = "cardfront"
+	cmd.lastQuestion = "back"
+	if err := cs.Save(chatID, cmd.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := cs.Load(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "/add" {
+		t.Errorf("got name %q; want %q", s.Name, "/add")
+	}
+	restored, err := s.AsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := restored.(*multiQuestionCommand)
+	if !ok {
+		t.Fatalf("got command %T; want *multiQuestionCommand", restored)
+	}
+	if got.lastQuestion != "back" {
+		t.Errorf("got lastQuestion %q; want %q", got.lastQuestion, "back")
+	}
+	var answers []string
+	for _, q := range got.questions {
+		answers = append(answers, q.answer)
+	}
+	if want := []string{"cardfront", ""}; !reflect.DeepEqual(answers, want) {
+		t.Errorf("got answers %q; want %q", answers, want)
+	}
+
+	// Fresh commands should not share answers with the restored one.
+	if a := AddCommandFactory()("/add").(*multiQuestionCommand).questions[0].answer; a != "" {
+		t.Errorf("new command has answer %q; want empty", a)
+	}
+
+	if err := cs.Save(chatID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := cs.Load(chatID); err != nil || s != nil {
+		t.Errorf("cs.Load after reset: %v, %v want nil, nil", s, err)
+	}
+}
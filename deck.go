@@ -0,0 +1,265 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Decks are named collections of words of a chat.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDeck contains words saved when no other deck was chosen. It always
+// exists.
+const DefaultDeck = "Default"
+
+// Argument of /practice to practice all decks.
+const allDecks = "all"
+
+type Deck struct {
+	Name  string
+	Cards int
+}
+
+// ValidateDeckName checks that the name can be used for a new deck.
+func ValidateDeckName(name string) error {
+	if name == "" {
+		return fmt.Errorf("deck name can't be empty")
+	}
+	if strings.EqualFold(name, allDecks) {
+		return fmt.Errorf("%q is reserved to practice all decks", name)
+	}
+	return nil
+}
+
+// NewDeck creates an empty deck.
+func (r *Repetition) NewDeck(chatID int64, name string) error {
+	if _, err := r.db.Exec(`
+		INSERT OR IGNORE INTO Decks(chat_id, name)
+		VALUES($0, $1)`,
+		chatID, name); err != nil {
+		return fmt.Errorf("INTERNAL: creating deck %q: %w", name, err)
+	}
+	return nil
+}
+
+// Decks returns all decks of the chat ordered by name.
+func (r *Repetition) Decks(chatID int64) ([]Deck, error) {
+	rows, err := r.db.Query(`
+		SELECT name, SUM(cards)
+		FROM (
+			SELECT name, 0 AS cards
+			FROM Decks
+			WHERE chat_id = $0
+			UNION ALL
+			SELECT deck, COUNT(*)
+			FROM Repetition
+			WHERE chat_id = $0
+			GROUP BY deck
+		)
+		GROUP BY name
+		ORDER BY name`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving decks for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var ds []Deck
+	hasDefault := false
+	for rows.Next() {
+		var d Deck
+		if err := rows.Scan(&d.Name, &d.Cards); err != nil {
+			return nil, err
+		}
+		hasDefault = hasDefault || d.Name == DefaultDeck
+		ds = append(ds, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !hasDefault {
+		ds = append([]Deck{{Name: DefaultDeck}}, ds...)
+	}
+	return ds, nil
+}
+
+// DeckExists reports whether the chat has the deck.
+func (r *Repetition) DeckExists(chatID int64, name string) (bool, error) {
+	ds, err := r.Decks(chatID)
+	if err != nil {
+		return false, err
+	}
+	for _, d := range ds {
+		if d.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RenameDeck renames the deck moving all its words.
+func (r *Repetition) RenameDeck(chatID int64, from, to string) error {
+	if _, err := r.db.Exec(`
+		UPDATE Repetition
+		SET deck = $0
+		WHERE chat_id = $1
+		  AND deck = $2`,
+		to, chatID, from); err != nil {
+		return fmt.Errorf("INTERNAL: renaming deck %q to %q: %w", from, to, err)
+	}
+	if _, err := r.db.Exec(`
+		DELETE FROM Decks
+		WHERE chat_id = $0
+		  AND name = $1`,
+		chatID, from); err != nil {
+		return fmt.Errorf("INTERNAL: renaming deck %q to %q: %w", from, to, err)
+	}
+	// Keep the deck even if it's empty.
+	if err := r.NewDeck(chatID, to); err != nil {
+		return err
+	}
+	return nil
+}
+
+const deckUsage = `Usage:
+  /deck list - list all decks
+  /deck new <name> - create a new deck
+  /deck use <name> - save new words into the deck
+  /deck rename <name> - rename the deck new words are saved into
+  /practice <name> - practice only the deck
+  /practice all - practice all decks
+  /practice #<tag> - practice only words with the tag`
+
+// deckReply handles /deck subcommands.
+func deckReply(s *State, chatID int64, args string) error {
+	sub, name := args, ""
+	if i := strings.Index(args, " "); i >= 0 {
+		sub, name = args[:i], strings.TrimSpace(args[i+1:])
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "list":
+		ds, err := s.Repetitions.Decks(chatID)
+		if err != nil {
+			return err
+		}
+		var b strings.Builder
+		b.WriteString("Decks:\n")
+		for _, d := range ds {
+			var marks []string
+			if d.Name == settings.ActiveDeck() {
+				marks = append(marks, "new words")
+			}
+			if d.Name == settings.PracticeDeck {
+				marks = append(marks, "practiced")
+			}
+			fmt.Fprintf(&b, "\n%s: %d words", d.Name, d.Cards)
+			if len(marks) > 0 {
+				fmt.Fprintf(&b, " (%s)", strings.Join(marks, ", "))
+			}
+		}
+		return s.Telegram.SendTextMessage(chatID, b.String())
+	case "new":
+		if err := ValidateDeckName(name); err != nil {
+			return UserError{ChatID: chatID, Err: err}
+		}
+		e, err := s.Repetitions.DeckExists(chatID, name)
+		if err != nil {
+			return err
+		}
+		if e {
+			return UserError{ChatID: chatID, Err: fmt.Errorf("Deck %q already exists.", name)}
+		}
+		if err := s.Repetitions.NewDeck(chatID, name); err != nil {
+			return err
+		}
+		return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Created deck %q. Use \"/deck use %s\" to save new words into it.", name, name))
+	case "use":
+		e, err := s.Repetitions.DeckExists(chatID, name)
+		if err != nil {
+			return err
+		}
+		if !e {
+			return UserError{ChatID: chatID, Err: fmt.Errorf("Deck %q doesn't exist.", name)}
+		}
+		if err := s.Settings.SetDeck(chatID, name); err != nil {
+			return err
+		}
+		return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("New words will be saved into deck %q.", name))
+	case "rename":
+		from := settings.ActiveDeck()
+		if err := ValidateDeckName(name); err != nil {
+			return UserError{ChatID: chatID, Err: err}
+		}
+		e, err := s.Repetitions.DeckExists(chatID, name)
+		if err != nil {
+			return err
+		}
+		if e {
+			return UserError{ChatID: chatID, Err: fmt.Errorf("Deck %q already exists.", name)}
+		}
+		if err := s.Repetitions.RenameDeck(chatID, from, name); err != nil {
+			return err
+		}
+		settings.Deck = name
+		if settings.PracticeDeck == from {
+			settings.PracticeDeck = name
+		}
+		if err := s.Settings.Set(chatID, settings); err != nil {
+			return err
+		}
+		return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Renamed deck %q to %q.", from, name))
+	}
+	return s.Telegram.SendTextMessage(chatID, deckUsage)
+}
+
+// practiceCommandReply optionally changes practiced words and starts the
+// practice. Arguments are a deck name or "all" and optionally a tag, e.g.
+// "German #verbs".
+func practiceCommandReply(s *State, chatID int64, args string) error {
+	if args != "" {
+		var (
+			scope Scope
+			deck  []string
+		)
+		for _, a := range strings.Fields(args) {
+			if strings.HasPrefix(a, "#") {
+				scope.Tag = normalizeTag(a)
+				continue
+			}
+			deck = append(deck, a)
+		}
+		scope.Deck = strings.Join(deck, " ")
+		if strings.EqualFold(scope.Deck, allDecks) {
+			scope.Deck = ""
+		}
+		if scope.Deck != "" {
+			e, err := s.Repetitions.DeckExists(chatID, scope.Deck)
+			if err != nil {
+				return err
+			}
+			if !e {
+				return UserError{ChatID: chatID, Err: fmt.Errorf("Deck %q doesn't exist.", scope.Deck)}
+			}
+		}
+		if err := s.Settings.SetPracticeScope(chatID, scope); err != nil {
+			return err
+		}
+	}
+	return practiceReply(s, chatID)
+}
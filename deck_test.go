@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "tmpdb")
+	// Database created before decks were introduced.
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE Repetition (
+			chat_id INTEGER,
+			word STRING,
+			definition STRING,
+			stage INTEGER,
+			last_updated_seconds INTEGER
+		);
+		INSERT INTO Repetition VALUES(1, "old", "old is old", 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	r, err := NewRepetition(dbPath, []time.Duration{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	if err := r.Save(chatID, "German", "schwarz", "schwarz is black"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.NewDeck(chatID, "Empty"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Decks(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Deck{{DefaultDeck, 1}, {"Empty", 0}, {"German", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decks: got %v; want %v", got, want)
+	}
+
+	for deck, want := range map[string]string{
+		"German":    "schwarz",
+		DefaultDeck: "old",
+	} {
+		if w, err := r.RepeatWord(chatID, Forward, Scope{Deck: deck}); err != nil || w != want {
+			t.Errorf("RepeatWord(%s): %q, %v want %q, nil", deck, w, err, want)
+		}
+	}
+	if w, err := r.RepeatWord(chatID, Forward, Scope{Deck: "Empty"}); err != sql.ErrNoRows {
+		t.Errorf("RepeatWord(Empty): %q, %v want sql.ErrNoRows", w, err)
+	}
+	if ws, err := r.DueWords(chatID, Forward, Scope{}); err != nil || len(ws) != 2 {
+		t.Errorf("DueWords: %q, %v want 2 words", ws, err)
+	}
+
+	if err := r.RenameDeck(chatID, "German", "Deutsch"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.Decks(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Deck{{DefaultDeck, 1}, {"Deutsch", 1}, {"Empty", 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decks after rename: got %v; want %v", got, want)
+	}
+	if e, err := r.DeckExists(chatID, "German"); err != nil || e {
+		t.Errorf("DeckExists(German): %t, %v want false, nil", e, err)
+	}
+}
+
+func TestPracticeAllDecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	if err := c.Repetitions.NewDeck(chatID, "German"); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"/practice German", "/practice All"} {
+		m := &Message{Text: text}
+		m.Chat.Id = chatID
+		if err := c.Update(&Update{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := c.Settings.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope := s.PracticeScope(); scope.Deck != "" {
+		t.Errorf("got practiced deck %q after /practice All; want all decks", scope.Deck)
+	}
+}
@@ -26,9 +26,10 @@ import (
 )
 
 type Definer struct {
-	usage *UsageFetcher
-	cache DefCacheInterface
-	http  *http.Client
+	usage     *UsageFetcher
+	cache     DefCacheInterface
+	http      *http.Client
+	languages *Languages
 }
 
 func (d *Definer) Define(word string, settings *Settings) (ds []string, err error) {
@@ -46,16 +47,16 @@ func (d *Definer) Define(word string, settings *Settings) (ds []string, err erro
 				return
 			}
 			if err := d.cache.Save(word, word, strings.Join(ds, separator)); err != nil {
-				log.Printf("cache.Save(%q): %w", word, err)
+				log.Printf("cache.Save(%q): %v", word, err)
 			}
 		}()
 	} else {
 		// At this point err != nil
-		log.Printf("ERROR: cache.Lookup(%q): %w", word, err)
+		log.Printf("ERROR: cache.Lookup(%q): %v", word, err)
 	}
 
 	p := WikiParser{
-		InputLanguage: settings.InputLanguage,
+		InputLanguage: d.languages.WikiSection(settings.InputLanguage),
 	}
 	defs, err := FetchWikiDefinition(p, d.http, word)
 	if err != nil {
@@ -63,7 +64,7 @@ func (d *Definer) Define(word string, settings *Settings) (ds []string, err erro
 	}
 	word = defs[0].Word
 
-	ex, err := d.usage.FetchExamples(word, settings.InputLanguageISO639_3, settings.TranslationLanguages)
+	ex, err := d.usage.FetchExamples(word, settings.InputLanguageISO639_3, settings.Translations())
 	if err != nil {
 		ex = nil
 		log.Printf("ERROR: FetchExamples(%s): %v", word, err)
@@ -269,6 +269,21 @@ func startFakeTelegram(t *testing.T) *fakeTelegram {
 	return fk
 }
 
+// startTestCommander starts the bot with the fake telegram and the database
+// in dir.
+func startTestCommander(t *testing.T, dir string) (*Commander, *fakeTelegram) {
+	fk := startFakeTelegram(t)
+	c, err := NewCommander(&Telegram{hc: *fk.server.Client()}, &CommanderOptions{
+		dbPath: filepath.Join(dir, "tmpdb"),
+		stages: []time.Duration{0, 2 * time.Minute},
+	})
+	if err != nil {
+		fk.server.Close()
+		t.Fatal(err)
+	}
+	return c, fk
+}
+
 type fakeTelegram struct {
 	server *httptest.Server
 	// all the messages ever received
@@ -0,0 +1,299 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Export of the saved words into files.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the JSON backup format. It should be increased on incompatible
+// changes, and ParseBackup should keep reading older versions.
+const backupVersion = 1
+
+// Backup is a JSON backup of all words of a chat, their progress and settings.
+type Backup struct {
+	Version         int       `json:"version"`
+	ExportedSeconds int64     `json:"exported_seconds"`
+	Settings        *Settings `json:"settings,omitempty"`
+	// All decks, including empty ones.
+	Decks []string      `json:"decks,omitempty"`
+	Words []*BackupWord `json:"words"`
+}
+
+type BackupSchedule struct {
+	Stage              int     `json:"stage"`
+	IntervalSeconds    int64   `json:"interval_seconds"`
+	Lapses             int     `json:"lapses"`
+	LastUpdatedSeconds int64   `json:"last_updated_seconds"`
+	Ease               float64 `json:"ease"`
+	Stability          float64 `json:"stability"`
+	Difficulty         float64 `json:"difficulty"`
+	DueSeconds         int64   `json:"due_seconds"`
+}
+
+type BackupReview struct {
+	Direction           Direction `json:"direction"`
+	Grade               Grade     `json:"grade"`
+	ReviewedSeconds     int64     `json:"reviewed_seconds"`
+	PrevStage           int       `json:"prev_stage"`
+	NewStage            int       `json:"new_stage"`
+	PrevIntervalSeconds int64     `json:"prev_interval_seconds"`
+	NewIntervalSeconds  int64     `json:"new_interval_seconds"`
+	// Missing if unknown.
+	LatencySeconds *int64 `json:"latency_seconds,omitempty"`
+}
+
+type BackupWord struct {
+	Word       string   `json:"word"`
+	Definition string   `json:"definition"`
+	Deck       string   `json:"deck"`
+	Tags       []string `json:"tags,omitempty"`
+	// Schedule of practicing the definition from the word.
+	BackupSchedule
+	// Schedule of practicing the word from the definition.
+	Reverse BackupSchedule `json:"reverse"`
+	Reviews []BackupReview `json:"reviews,omitempty"`
+	// Suspending and burying applies to both directions.
+	Suspended          bool  `json:"suspended,omitempty"`
+	BuriedUntilSeconds int64 `json:"buried_until_seconds,omitempty"`
+}
+
+func newBackupSchedule(c *Card, due time.Time) BackupSchedule {
+	return BackupSchedule{
+		Stage:              c.Stage,
+		IntervalSeconds:    int64(c.Interval.Seconds()),
+		Lapses:             c.Lapses,
+		LastUpdatedSeconds: c.LastReview.Unix(),
+		Ease:               c.Ease,
+		Stability:          c.Stability,
+		Difficulty:         c.Difficulty,
+		DueSeconds:         due.Unix(),
+	}
+}
+
+func (b BackupSchedule) card() (*Card, time.Time) {
+	return &Card{
+		Stage:      b.Stage,
+		Interval:   time.Duration(b.IntervalSeconds) * time.Second,
+		Lapses:     b.Lapses,
+		LastReview: time.Unix(b.LastUpdatedSeconds, 0),
+		Ease:       b.Ease,
+		Stability:  b.Stability,
+		Difficulty: b.Difficulty,
+	}, time.Unix(b.DueSeconds, 0)
+}
+
+func newBackupReview(dir Direction, r Review) BackupReview {
+	b := BackupReview{
+		Direction:           dir,
+		Grade:               r.Grade,
+		ReviewedSeconds:     r.Time.Unix(),
+		PrevStage:           r.PrevStage,
+		NewStage:            r.NewStage,
+		PrevIntervalSeconds: int64(r.PrevInterval.Seconds()),
+		NewIntervalSeconds:  int64(r.NewInterval.Seconds()),
+	}
+	if r.Latency >= 0 {
+		l := int64(r.Latency.Seconds())
+		b.LatencySeconds = &l
+	}
+	return b
+}
+
+func (b BackupReview) review() Review {
+	r := Review{
+		Time:         time.Unix(b.ReviewedSeconds, 0),
+		Grade:        b.Grade,
+		PrevStage:    b.PrevStage,
+		NewStage:     b.NewStage,
+		PrevInterval: time.Duration(b.PrevIntervalSeconds) * time.Second,
+		NewInterval:  time.Duration(b.NewIntervalSeconds) * time.Second,
+		Latency:      -1,
+	}
+	if b.LatencySeconds != nil {
+		r.Latency = time.Duration(*b.LatencySeconds) * time.Second
+	}
+	return r
+}
+
+// NewBackup creates a backup of the cards.
+func NewBackup(cards []*CardRecord, decks []Deck, settings *Settings, now time.Time) *Backup {
+	b := &Backup{
+		Version:         backupVersion,
+		ExportedSeconds: now.Unix(),
+		Settings:        settings,
+		Words:           []*BackupWord{},
+	}
+	for _, d := range decks {
+		b.Decks = append(b.Decks, d.Name)
+	}
+	for _, c := range cards {
+		w := &BackupWord{
+			Word:       c.Front,
+			Definition: c.Back,
+			Deck:       c.Deck,
+			Tags:       c.Tags,
+			Suspended:  c.Suspended,
+		}
+		if !c.BuriedUntil.IsZero() {
+			w.BuriedUntilSeconds = c.BuriedUntil.Unix()
+		}
+		if c.Schedule != nil {
+			w.BackupSchedule = newBackupSchedule(c.Schedule, c.Due)
+		}
+		if c.ReverseSchedule != nil {
+			w.Reverse = newBackupSchedule(c.ReverseSchedule, c.ReverseDue)
+		}
+		for _, r := range c.Reviews {
+			w.Reviews = append(w.Reviews, newBackupReview(Forward, r))
+		}
+		for _, r := range c.ReverseReviews {
+			w.Reviews = append(w.Reviews, newBackupReview(Reverse, r))
+		}
+		b.Words = append(b.Words, w)
+	}
+	return b
+}
+
+// ParseBackup reads JSON backup.
+func ParseBackup(data []byte) (*Backup, error) {
+	b := &Backup{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+	if b.Version < 1 || b.Version > backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d, latest supported is %d", b.Version, backupVersion)
+	}
+	return b, nil
+}
+
+// Cards returns words of the backup.
+func (b *Backup) Cards() []*CardRecord {
+	var cs []*CardRecord
+	for _, w := range b.Words {
+		c := &CardRecord{
+			Front:     w.Word,
+			Back:      w.Definition,
+			Deck:      w.Deck,
+			Tags:      w.Tags,
+			Suspended: w.Suspended,
+		}
+		if w.BuriedUntilSeconds != 0 {
+			c.BuriedUntil = time.Unix(w.BuriedUntilSeconds, 0)
+		}
+		c.Schedule, c.Due = w.BackupSchedule.card()
+		c.ReverseSchedule, c.ReverseDue = w.Reverse.card()
+		for _, r := range w.Reviews {
+			if r.Direction == Reverse {
+				c.ReverseReviews = append(c.ReverseReviews, r.review())
+			} else {
+				c.Reviews = append(c.Reviews, r.review())
+			}
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// WriteCSV writes cards with their forward schedule, see csvColumns. It's a
+// partial export for spreadsheets: reverse schedule, reviews, suspended and
+// buried state and settings are only kept by the JSON backup.
+func WriteCSV(cards []*CardRecord) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	for _, c := range cards {
+		rec := []string{c.Front, c.Back, strings.Join(c.Tags, " "), c.Deck}
+		if s := c.Schedule; s != nil {
+			rec = append(rec,
+				strconv.Itoa(s.Stage),
+				strconv.FormatInt(int64(s.Interval.Seconds()), 10),
+				strconv.Itoa(s.Lapses),
+				strconv.FormatInt(s.LastReview.Unix(), 10),
+				strconv.FormatFloat(s.Ease, 'g', -1, 64),
+				strconv.FormatFloat(s.Stability, 'g', -1, 64),
+				strconv.FormatFloat(s.Difficulty, 'g', -1, 64),
+				strconv.FormatInt(c.Due.Unix(), 10),
+			)
+		}
+		if err := w.Write(rec); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+const exportUsage = `Usage:
+  /export json - backup of the words, their progress and settings
+  /export csv - words and their forward progress as a spreadsheet, without
+    reverse progress, review history, suspended words and settings
+  /export anki - Anki package (.apkg)
+
+Files can be imported back with /import. Only JSON is a full backup.`
+
+// exportReply sends all words of the chat as a file in the requested format.
+// JSON backup is sent if format is empty.
+func exportReply(s *State, chatID int64, format string) error {
+	cards, err := s.Repetitions.Records(chatID)
+	if err != nil {
+		return err
+	}
+	var (
+		name string
+		data []byte
+	)
+	caption := fmt.Sprintf("Exported %d words.", len(cards))
+	now := time.Now()
+	switch format {
+	case "", "json":
+		settings, err := s.Settings.Get(chatID)
+		if err != nil {
+			return err
+		}
+		decks, err := s.Repetitions.Decks(chatID)
+		if err != nil {
+			return err
+		}
+		if data, err = json.MarshalIndent(NewBackup(cards, decks, settings, now), "", "  "); err != nil {
+			return fmt.Errorf("exporting JSON: %w", err)
+		}
+		name = "words.json"
+	case "csv":
+		if data, err = WriteCSV(cards); err != nil {
+			return fmt.Errorf("exporting CSV: %w", err)
+		}
+		name = "words.csv"
+		caption += " CSV keeps only the words and their forward progress, use /export json for a full backup."
+	case "anki":
+		if data, err = WriteApkg(cards, now); err != nil {
+			return fmt.Errorf("exporting Anki package: %w", err)
+		}
+		name = "words.apkg"
+	default:
+		return s.Telegram.SendTextMessage(chatID, exportUsage)
+	}
+	return s.Telegram.SendDocument(chatID, name, data, caption)
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "tmpdb")
+	r, err := NewRepetition(dbPath, []time.Duration{0, time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewSettingsConfig(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{&Clients{Repetitions: r, Settings: sc}}
+
+	const chatID, restoredID int64 = 1, 2
+	settings := DefaultSettings()
+	settings.Scheduler = FSRSScheduler
+	settings.Deck = "Verbs"
+	if err := sc.Set(chatID, settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.NewDeck(chatID, "Empty"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(chatID, "Verbs", "fut", "to run, \"quickly\""); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(chatID, "", "kutya", "dog"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddTags(chatID, "fut", []string{"motion", "chapter-3"}); err != nil {
+		t.Fatal(err)
+	}
+	last := time.Unix(1600000000, 0)
+	c := Card{Stage: 2, Interval: 3 * day, Lapses: 1, LastReview: last, Ease: 2.35, Stability: 3.5, Difficulty: 4.25}
+	if err := r.SetSchedule(chatID, "fut", Forward, c, last.Add(c.Interval)); err != nil {
+		t.Fatal(err)
+	}
+	rc := Card{Stage: 1, Interval: day, LastReview: last, Ease: 2.5}
+	if err := r.SetSchedule(chatID, "fut", Reverse, rc, last.Add(rc.Interval)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddReviews(chatID, "fut", Forward, []Review{
+		{Time: last.Add(-day), Grade: GradeAgain, NewStage: 1, NewInterval: time.Hour, Latency: -1},
+		{Time: last, Grade: GradeGood, PrevStage: 1, NewStage: 2, PrevInterval: time.Hour, NewInterval: 3 * day, Latency: 7 * time.Second},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddReviews(chatID, "fut", Reverse, []Review{
+		{Time: last, Grade: GradeHard, NewStage: 1, NewInterval: day, Latency: 12 * time.Second},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Suspend(chatID, "kutya", true); err != nil {
+		t.Fatal(err)
+	}
+	buried := time.Unix(time.Now().Add(day).Unix(), 0)
+	if err := r.Bury(chatID, "fut", buried); err != nil {
+		t.Fatal(err)
+	}
+	want, err := r.Records(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decks, err := r.Decks(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(NewBackup(want, decks, settings, last))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseBackup(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreBackup(s, restoredID, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := importCards(s, restoredID, b.Cards(), &ImportResult{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Records(restoredID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON: got %+v; want %+v", got, want)
+	}
+	if got, err := sc.Get(restoredID); err != nil || !reflect.DeepEqual(got, settings) {
+		t.Errorf("JSON settings: got %+v, %v; want %+v", got, err, settings)
+	}
+	if got, err := r.Decks(restoredID); err != nil || !reflect.DeepEqual(got, decks) {
+		t.Errorf("JSON decks: got %+v, %v; want %+v", got, err, decks)
+	}
+
+	// CSV keeps only the forward schedule, suspended and buried words are
+	// practiced again.
+	data, err = WriteCSV(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards, failed := ParseCSV("words.csv", data)
+	if len(failed) > 0 {
+		t.Errorf("CSV failed: %q", failed)
+	}
+	for _, c := range want {
+		c.ReverseSchedule, c.ReverseDue, c.Reviews, c.ReverseReviews = nil, time.Time{}, nil, nil
+		c.Suspended, c.BuriedUntil = false, time.Time{}
+	}
+	if !reflect.DeepEqual(cards, want) {
+		t.Errorf("CSV: got %+v; want %+v", cards, want)
+	}
+}
+
+func TestParseBackupVersion(t *testing.T) {
+	for _, data := range []string{`{"version": 2, "words": []}`, `{"words": []}`, `[]`} {
+		if _, err := ParseBackup([]byte(data)); err == nil {
+			t.Errorf("ParseBackup(%s): got nil error; want error", data)
+		}
+	}
+}
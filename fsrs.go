@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Implementation of Free Spaced Repetition Scheduler (FSRS v4.5).
+// See https://github.com/open-spaced-repetition/fsrs4anki/wiki/The-Algorithm
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Probability of recalling the card at the time it's due.
+	fsrsRetention = 0.9
+	fsrsDecay     = -0.5
+	// Chosen so that retrievability is 0.9 when elapsed time equals stability.
+	fsrsFactor      = 19.0 / 81.0
+	fsrsMaxInterval = 36500 * day
+)
+
+// Default model parameters.
+var fsrsWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031,
+	1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+type fsrsScheduler struct {
+	// Forgotten cards are shown again after relearn duration.
+	relearn   time.Duration
+	retention float64
+	w         [17]float64
+}
+
+// rating converts grade into FSRS rating 1-4.
+func (fsrsScheduler) rating(g Grade) float64 {
+	return float64(g) + 1
+}
+
+// retrievability is the probability of recall after elapsed days.
+func (fsrsScheduler) retrievability(elapsed, stability float64) float64 {
+	return math.Pow(1+fsrsFactor*elapsed/stability, fsrsDecay)
+}
+
+func (f fsrsScheduler) initStability(g Grade) float64 {
+	return math.Max(f.w[int(g)], 0.1)
+}
+
+func (f fsrsScheduler) initDifficulty(g Grade) float64 {
+	return f.w[4] - (f.rating(g)-3)*f.w[5]
+}
+
+func (f fsrsScheduler) nextDifficulty(d float64, g Grade) float64 {
+	nd := d - f.w[6]*(f.rating(g)-3)
+	// Mean reversion towards the difficulty of a card answered "Good".
+	nd = f.w[7]*f.initDifficulty(GradeGood) + (1-f.w[7])*nd
+	return math.Min(math.Max(nd, 1), 10)
+}
+
+func (f fsrsScheduler) nextStability(s, d, r float64, g Grade) float64 {
+	if g == GradeAgain {
+		fs := f.w[11] * math.Pow(d, -f.w[12]) * (math.Pow(s+1, f.w[13]) - 1) * math.Exp(f.w[14]*(1-r))
+		// Forgetting shouldn't make the memory stronger.
+		return math.Min(fs, s)
+	}
+	m := 1.0
+	switch g {
+	case GradeHard:
+		m = f.w[15]
+	case GradeEasy:
+		m = f.w[16]
+	}
+	return s * (1 + math.Exp(f.w[8])*(11-d)*math.Pow(s, -f.w[9])*(math.Exp(f.w[10]*(1-r))-1)*m)
+}
+
+// review updates memory state of the card answered with the grade after
+// elapsed time.
+func (f fsrsScheduler) review(c Card, g Grade, elapsed time.Duration) Card {
+	if c.Stability <= 0 {
+		c.Stability = f.initStability(g)
+		c.Difficulty = math.Min(math.Max(f.initDifficulty(g), 1), 10)
+		return c
+	}
+	r := f.retrievability(elapsed.Hours()/24, c.Stability)
+	c.Stability = f.nextStability(c.Stability, c.Difficulty, r, g)
+	c.Difficulty = f.nextDifficulty(c.Difficulty, g)
+	return c
+}
+
+// Schedule returns the state of the card after it was answered with the grade.
+// Cards that were scheduled by other schedulers before have no memory state,
+// in that case it's reconstructed from the history.
+func (f fsrsScheduler) Schedule(c Card, history []Review, g Grade, now time.Time) Card {
+	if c.Stability <= 0 && len(history) > 0 {
+		for i, h := range history {
+			var elapsed time.Duration
+			if i > 0 {
+				elapsed = h.Time.Sub(history[i-1].Time)
+			}
+			c = f.review(c, h.Grade, elapsed)
+		}
+		c.LastReview = history[len(history)-1].Time
+	}
+	c = f.review(c, g, now.Sub(c.LastReview))
+
+	if g == GradeAgain {
+		c.Lapses++
+		c.Stage = 0
+		c.Interval = f.relearn
+		return c
+	}
+	c.Stage++
+	days := math.Round(c.Stability / fsrsFactor * (math.Pow(f.retention, 1/fsrsDecay) - 1))
+	c.Interval = time.Duration(days) * day
+	if c.Interval < day {
+		c.Interval = day
+	}
+	if c.Interval > fsrsMaxInterval {
+		c.Interval = fsrsMaxInterval
+	}
+	return c
+}
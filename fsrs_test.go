@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFSRS(t *testing.T) {
+	const relearn = 10 * time.Minute
+	f := fsrsScheduler{relearn: relearn, retention: fsrsRetention, w: fsrsWeights}
+	now := time.Unix(0, 0)
+
+	// First review initializes memory state from the grade.
+	var firsts []Card
+	for _, g := range Grades {
+		c := f.Schedule(Card{}, nil, g, now)
+		if c.Stability != fsrsWeights[int(g)] {
+			t.Errorf("%v: got stability %v; want %v", g, c.Stability, fsrsWeights[int(g)])
+		}
+		firsts = append(firsts, c)
+	}
+	if firsts[GradeAgain].Interval != relearn {
+		t.Errorf("Again: got interval %v; want %v", firsts[GradeAgain].Interval, relearn)
+	}
+	for i := 1; i < len(firsts); i++ {
+		if firsts[i].Difficulty >= firsts[i-1].Difficulty {
+			t.Errorf("got difficulty %v for %v, want less than %v for %v", firsts[i].Difficulty, Grades[i], firsts[i-1].Difficulty, Grades[i-1])
+		}
+	}
+
+	// With 90% retention interval is equal to stability.
+	c := firsts[GradeGood]
+	for i := 0; i < 5; i++ {
+		prev := c
+		now = now.Add(c.Interval)
+		c = f.Schedule(c, nil, GradeGood, now)
+		if c.Stability <= prev.Stability {
+			t.Errorf("review %d: stability %v didn't grow from %v", i, c.Stability, prev.Stability)
+		}
+		if want := time.Duration(math.Round(c.Stability)) * day; c.Interval != want {
+			t.Errorf("review %d: got interval %v; want %v", i, c.Interval, want)
+		}
+		c.LastReview = now
+	}
+
+	forgotten := f.Schedule(c, nil, GradeAgain, now.Add(c.Interval))
+	if forgotten.Stability >= c.Stability || forgotten.Lapses != 1 || forgotten.Stage != 0 {
+		t.Errorf("Again: got %+v after %+v; want lower stability and a lapse", forgotten, c)
+	}
+
+	// Memory state is rebuilt from the history for cards scheduled by other
+	// schedulers.
+	start := time.Unix(0, 0)
+	history := []Review{
+		{Time: start, Grade: GradeGood},
+		{Time: start.Add(3 * day), Grade: GradeGood},
+	}
+	replayed := f.Schedule(Card{Stage: 2}, history, GradeGood, start.Add(10*day))
+	manual := f.Schedule(Card{}, nil, GradeGood, start)
+	manual.LastReview = start
+	manual = f.Schedule(manual, nil, GradeGood, start.Add(3*day))
+	manual.LastReview = start.Add(3 * day)
+	manual = f.Schedule(manual, nil, GradeGood, start.Add(10*day))
+	if replayed.Stability != manual.Stability || replayed.Difficulty != manual.Difficulty {
+		t.Errorf("got replayed state (%v, %v); want (%v, %v)", replayed.Stability, replayed.Difficulty, manual.Stability, manual.Difficulty)
+	}
+}
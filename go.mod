@@ -1,6 +1,6 @@
 module words
 
-go 1.13
+go 1.15
 
 require (
 	github.com/google/go-cmp v0.4.0
@@ -0,0 +1,353 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Bulk import of words from files sent to the bot.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum number of failed rows listed in the import report.
+const maxReportedFailures = 10
+
+// CardRecord is a word as it's imported or exported.
+type CardRecord struct {
+	Front string
+	Back  string
+	Tags  []string
+	// Deck of the word, active deck is used on import if empty.
+	Deck string
+	// Schedule of the word, nil if it's a new word.
+	Schedule *Card
+	Due      time.Time
+	// Schedule of practicing the word from its definition, nil if unknown.
+	ReverseSchedule *Card
+	ReverseDue      time.Time
+	// History of the word in both directions, oldest first.
+	Reviews        []Review
+	ReverseReviews []Review
+	// Suspended words aren't practiced, buried ones aren't practiced until
+	// BuriedUntil.
+	Suspended   bool
+	BuriedUntil time.Time
+}
+
+// ImportResult summarizes an import.
+type ImportResult struct {
+	Imported   int
+	Duplicates int
+	// Rows which couldn't be parsed or saved.
+	Failed []string
+}
+
+func (r *ImportResult) String() string {
+	msg := fmt.Sprintf("Imported %d rows, skipped %d duplicates.", r.Imported, r.Duplicates)
+	if len(r.Failed) == 0 {
+		return msg
+	}
+	msg += fmt.Sprintf("\n\nFailed to import %d rows:", len(r.Failed))
+	for i, f := range r.Failed {
+		if i == maxReportedFailures {
+			msg += fmt.Sprintf("\n...and %d more", len(r.Failed)-i)
+			break
+		}
+		msg += "\n" + f
+	}
+	return msg
+}
+
+// Columns of the CSV export. Only front and back are required on import.
+var csvColumns = []string{
+	"front", "back", "tags", "deck",
+	"stage", "interval_seconds", "lapses", "last_updated_seconds",
+	"ease", "stability", "difficulty", "due_seconds",
+}
+
+// ParseCSV reads cards from CSV or TSV with front, back and optional tags
+// columns. Tab separated values are expected if the file has .tsv extension
+// or the first line contains tabs. If the first row is a header starting with
+// front and back, columns are matched by their names, see csvColumns, which
+// allows to import schedule of the words. Rows which couldn't be parsed are
+// returned separately.
+func ParseCSV(name string, data []byte) ([]*CardRecord, []string) {
+	r := csv.NewReader(bytes.NewReader(data))
+	first := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		first = data[:i]
+	}
+	if strings.EqualFold(filepath.Ext(name), ".tsv") || bytes.IndexByte(first, '\t') >= 0 {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var (
+		cards  []*CardRecord
+		failed []string
+		// Names of the columns if there is a header.
+		header []string
+	)
+	for row := 1; ; row++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			failed = append(failed, fmt.Sprintf("row %d: %v", row, pe.Err))
+			continue
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("row %d: %v", row, err))
+			break
+		}
+		if row == 1 && len(rec) >= 2 &&
+			strings.EqualFold(strings.TrimSpace(rec[0]), "front") &&
+			strings.EqualFold(strings.TrimSpace(rec[1]), "back") {
+			for _, h := range rec {
+				header = append(header, strings.ToLower(strings.TrimSpace(h)))
+			}
+			continue
+		}
+		fields := make(map[string]string)
+		switch {
+		case header != nil && len(rec) <= len(header) && len(rec) >= 2:
+			for i, f := range rec {
+				fields[header[i]] = strings.TrimSpace(f)
+			}
+		case header == nil && len(rec) >= 2 && len(rec) <= 3:
+			for i, f := range rec {
+				fields[csvColumns[i]] = strings.TrimSpace(f)
+			}
+		default:
+			failed = append(failed, fmt.Sprintf("row %d: got %d columns; want front, back and optional tags", row, len(rec)))
+			continue
+		}
+		c, err := cardFromFields(fields)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("row %d: %v", row, err))
+			continue
+		}
+		cards = append(cards, c)
+	}
+	return cards, failed
+}
+
+// cardFromFields creates a card from CSV fields keyed by column names.
+func cardFromFields(fields map[string]string) (*CardRecord, error) {
+	c := &CardRecord{
+		Front: fields["front"],
+		Back:  fields["back"],
+		Tags:  ParseTags(fields["tags"]),
+		Deck:  fields["deck"],
+	}
+	if c.Front == "" || c.Back == "" {
+		return nil, fmt.Errorf("front and back can't be empty")
+	}
+	if fields["stage"] == "" {
+		return c, nil
+	}
+	var (
+		sc  Card
+		err error
+		// Parse fields until the first error, empty fields are zero.
+		integer = func(name string) int64 {
+			if err != nil || fields[name] == "" {
+				return 0
+			}
+			var v int64
+			if v, err = strconv.ParseInt(fields[name], 10, 64); err != nil {
+				err = fmt.Errorf("column %s: %w", name, err)
+			}
+			return v
+		}
+		float = func(name string) float64 {
+			if err != nil || fields[name] == "" {
+				return 0
+			}
+			var v float64
+			if v, err = strconv.ParseFloat(fields[name], 64); err != nil {
+				err = fmt.Errorf("column %s: %w", name, err)
+			}
+			return v
+		}
+	)
+	sc.Stage = int(integer("stage"))
+	sc.Interval = time.Duration(integer("interval_seconds")) * time.Second
+	sc.Lapses = int(integer("lapses"))
+	sc.LastReview = time.Unix(integer("last_updated_seconds"), 0)
+	sc.Ease = float("ease")
+	sc.Stability = float("stability")
+	sc.Difficulty = float("difficulty")
+	due := integer("due_seconds")
+	if err != nil {
+		return nil, err
+	}
+	c.Schedule = &sc
+	c.Due = time.Unix(due, 0)
+	return c, nil
+}
+
+// importCards saves cards into the active deck of the chat skipping already
+// saved words. Schedule of the words is restored if it's known.
+func importCards(s *State, chatID int64, cards []*CardRecord, res *ImportResult) error {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	for _, c := range cards {
+		deck := c.Deck
+		if deck == "" {
+			deck = settings.ActiveDeck()
+		}
+		err := s.Repetitions.Save(chatID, deck, c.Front, c.Back)
+		if err == ErrWordExists {
+			res.Duplicates++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if c.Schedule != nil {
+			if err := s.Repetitions.SetSchedule(chatID, c.Front, Forward, *c.Schedule, c.Due); err != nil {
+				return err
+			}
+		}
+		if c.ReverseSchedule != nil {
+			if err := s.Repetitions.SetSchedule(chatID, c.Front, Reverse, *c.ReverseSchedule, c.ReverseDue); err != nil {
+				return err
+			}
+		}
+		if err := s.Repetitions.AddReviews(chatID, c.Front, Forward, c.Reviews); err != nil {
+			return err
+		}
+		if err := s.Repetitions.AddReviews(chatID, c.Front, Reverse, c.ReverseReviews); err != nil {
+			return err
+		}
+		if err := s.Repetitions.AddTags(chatID, c.Front, c.Tags); err != nil {
+			return err
+		}
+		if c.Suspended {
+			if err := s.Repetitions.Suspend(chatID, c.Front, true); err != nil {
+				return err
+			}
+		}
+		if c.BuriedUntil.After(time.Now()) {
+			if err := s.Repetitions.Bury(chatID, c.Front, c.BuriedUntil); err != nil {
+				return err
+			}
+		}
+		res.Imported++
+	}
+	return nil
+}
+
+// restoreBackup restores settings and decks from the backup. Words are
+// imported separately.
+func restoreBackup(s *State, chatID int64, b *Backup) error {
+	if b.Settings != nil {
+		if err := s.Settings.Set(chatID, b.Settings); err != nil {
+			return err
+		}
+	}
+	for _, d := range b.Decks {
+		if err := s.Repetitions.NewDeck(chatID, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importCommand waits for a file to import.
+type importCommand struct {
+	name string
+}
+
+func (c *importCommand) Serialize() *SerializedCommand {
+	return &SerializedCommand{Name: c.name}
+}
+
+func (c *importCommand) Init(*SerializedCommand) error {
+	return nil
+}
+
+func (c *importCommand) OnCommand(s *State, m *Message) (Command, error) {
+	return c, s.Telegram.SendTextMessage(m.Chat.Id, "Send a CSV or TSV file with front, back and optional tags columns, a JSON backup made by /export or an Anki package (.apkg).")
+}
+
+func (c *importCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	d := m.Document
+	if d == nil {
+		return c, UserError{ChatID: chatID, Err: fmt.Errorf("Please send a file to import.")}
+	}
+	if d.FileSize > maxFileSize {
+		return nil, UserError{ChatID: chatID, Err: fmt.Errorf("File is too large, maximum size is %d MB.", maxFileSize>>20)}
+	}
+	data, err := s.Telegram.DownloadFile(d.FileId)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %q: %w", d.FileName, err)
+	}
+	var (
+		cards  []*CardRecord
+		failed []string
+	)
+	var backup *Backup
+	switch strings.ToLower(filepath.Ext(d.FileName)) {
+	case ".json":
+		backup, err = ParseBackup(data)
+		if err != nil {
+			return nil, UserError{ChatID: chatID, Err: fmt.Errorf("Couldn't read backup: %w", err)}
+		}
+		cards = backup.Cards()
+	case ".apkg":
+		cards, failed, err = ParseApkg(data, s.Repetitions.StageFor)
+		if err != nil {
+			return nil, UserError{ChatID: chatID, Err: fmt.Errorf("Couldn't read Anki package: %w", err)}
+		}
+	default:
+		cards, failed = ParseCSV(d.FileName, data)
+	}
+	res := &ImportResult{Failed: failed}
+	if backup != nil {
+		if err := restoreBackup(s, chatID, backup); err != nil {
+			return nil, err
+		}
+	}
+	if err := importCards(s, chatID, cards, res); err != nil {
+		return nil, err
+	}
+	msg := res.String()
+	if backup != nil && backup.Settings != nil {
+		msg += "\n\nSettings were restored."
+	}
+	return nil, s.Telegram.SendTextMessage(chatID, msg)
+}
+
+func ImportCommandFactory() CommandFactory {
+	return func(name string) Command {
+		return &importCommand{name: name}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	for _, tc := range []struct {
+		name, data string
+		want       []*CardRecord
+		wantFailed []string
+	}{{
+		name: "words.csv",
+		data: "Front,Back,Tags\nfekete,black,\"colors, adjectives\"\nkutya,dog\n",
+		want: []*CardRecord{
+			{Front: "fekete", Back: "black", Tags: []string{"colors", "adjectives"}},
+			{Front: "kutya", Back: "dog"},
+		},
+	}, {
+		name: "words.tsv",
+		data: "fekete\tblack \"dark\"\nfut\tto run\tverbs\n",
+		want: []*CardRecord{
+			{Front: "fekete", Back: "black \"dark\""},
+			{Front: "fut", Back: "to run", Tags: []string{"verbs"}},
+		},
+	}, {
+		name: "words.txt",
+		data: "fekete\tblack\n",
+		want: []*CardRecord{{Front: "fekete", Back: "black"}},
+	}, {
+		name: "broken.csv",
+		data: "fekete\nkutya,dog\n,empty\na,b,c,d\n",
+		want: []*CardRecord{{Front: "kutya", Back: "dog"}},
+		wantFailed: []string{
+			"row 1: got 1 columns; want front, back and optional tags",
+			"row 3: front and back can't be empty",
+			"row 4: got 4 columns; want front, back and optional tags",
+		},
+	}} {
+		got, failed := ParseCSV(tc.name, []byte(tc.data))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseCSV(%s): got %+v; want %+v", tc.name, got, tc.want)
+		}
+		if !reflect.DeepEqual(failed, tc.wantFailed) {
+			t.Errorf("ParseCSV(%s) failed: got %q; want %q", tc.name, failed, tc.wantFailed)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Registry of supported input languages. Built-in languages can be replaced
+// with a JSON file in the same format as defaultLanguagesData, so that new
+// languages can be added without recompiling.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+type Language struct {
+	// Name shown to the user, e.g. "Hungarian".
+	Name string `json:"name"`
+	// Header of the language section on Wiktionary. Name is used if empty.
+	WikiSection string `json:"wiki_section"`
+	// Code used by Tatoeba for sentences.
+	ISO639_3 string `json:"iso639_3"`
+	// Languages of usage examples' translations for new users of the language.
+	TranslationLanguages []string  `json:"translation_languages"`
+	Tokenizer            Tokenizer `json:"tokenizer"`
+	// Input language of new users. The first language is used if none is
+	// marked.
+	Default bool `json:"default"`
+}
+
+// Languages are the supported input languages by their names.
+type Languages struct {
+	byName map[string]*Language
+	def    *Language
+}
+
+// defaultLanguages are built into the binary.
+var defaultLanguages = func() *Languages {
+	l, err := ParseLanguages([]byte(defaultLanguagesData))
+	if err != nil {
+		panic(err)
+	}
+	return l
+}()
+
+// LoadLanguages reads languages from the JSON file, built-in languages are
+// returned if path is empty.
+func LoadLanguages(path string) (*Languages, error) {
+	if path == "" {
+		return defaultLanguages, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ParseLanguages(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", path, err)
+	}
+	return l, nil
+}
+
+// ParseLanguages parses and validates a JSON list of languages. Tokenizer
+// rules which aren't set are the same as for defaultTokenizer.
+func ParseLanguages(data []byte) (*Languages, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no languages")
+	}
+	l := &Languages{byName: make(map[string]*Language)}
+	var first *Language
+	for i, r := range raw {
+		lang := &Language{Tokenizer: defaultTokenizer}
+		if err := json.Unmarshal(r, lang); err != nil {
+			return nil, fmt.Errorf("language %d: %w", i+1, err)
+		}
+		if lang.Name == "" {
+			return nil, fmt.Errorf("language %d: name can't be empty", i+1)
+		}
+		if _, ok := l.byName[lang.Name]; ok {
+			return nil, fmt.Errorf("language %q is listed twice", lang.Name)
+		}
+		if lang.WikiSection == "" {
+			lang.WikiSection = lang.Name
+		}
+		for _, c := range append([]string{lang.ISO639_3}, lang.TranslationLanguages...) {
+			if !isISO639_3(c) {
+				return nil, fmt.Errorf("language %q: %q isn't an ISO 639-3 code", lang.Name, c)
+			}
+		}
+		if err := lang.Tokenizer.validate(); err != nil {
+			return nil, fmt.Errorf("language %q: %w", lang.Name, err)
+		}
+		if lang.Default {
+			if l.def != nil {
+				return nil, fmt.Errorf("languages %q and %q are both default", l.def.Name, lang.Name)
+			}
+			l.def = lang
+		}
+		if i == 0 {
+			first = lang
+		}
+		l.byName[lang.Name] = lang
+	}
+	if l.def == nil {
+		l.def = first
+	}
+	return l, nil
+}
+
+// isISO639_3 reports whether the code looks like ISO 639-3, e.g. "hun".
+func isISO639_3(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Languages) Get(name string) (*Language, bool) {
+	lang, ok := l.byName[name]
+	return lang, ok
+}
+
+// Default returns the input language of new users.
+func (l *Languages) Default() *Language {
+	return l.def
+}
+
+// Names returns sorted names of all languages.
+func (l *Languages) Names() []string {
+	var ns []string
+	for n := range l.byName {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// WikiSection returns the Wiktionary section of the language. Name is used
+// for languages which aren't supported anymore.
+func (l *Languages) WikiSection(name string) string {
+	if lang, ok := l.Get(name); ok {
+		return lang.WikiSection
+	}
+	return name
+}
+
+// Tokenizer returns tokenizer rules of the language, or the default ones for
+// languages which aren't supported anymore.
+func (l *Languages) Tokenizer(name string) Tokenizer {
+	if lang, ok := l.Get(name); ok {
+		return lang.Tokenizer
+	}
+	return defaultTokenizer
+}
+
+// LanguageCommand asks for the input language among the supported ones.
+type LanguageCommand struct{}
+
+func (LanguageCommand) Ask(s *State, chatID int64) error {
+	var ls []string
+	for _, l := range s.Settings.Languages.Names() {
+		ls = append(ls, fmt.Sprintf("%q", l))
+	}
+	return s.Telegram.SendTextMessage(chatID, fmt.Sprintf(
+		"Enter input language of your choice. Supported are %s",
+		strings.Join(ls, ",")))
+}
+
+func (LanguageCommand) Validate(s *State, m *Message) error {
+	if err := s.Settings.ValidateLanguage(m.Text); err != nil {
+		return UserError{ChatID: m.Chat.Id, Err: fmt.Errorf("%w. Please try again.", err)}
+	}
+	return nil
+}
+
+func (LanguageCommand) Save(s *State, chatID int64, answer string) error {
+	if err := s.Settings.SetLanguage(chatID, answer); err != nil {
+		return err
+	}
+	return settingsReply(s, chatID)
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Built-in input languages, see LoadLanguages to use other ones.
+package main
+
+const defaultLanguagesData = `[
+  {
+    "name": "Hungarian",
+    "wiki_section": "Hungarian",
+    "iso639_3": "hun",
+    "translation_languages": ["eng", "rus", "ukr"],
+    "tokenizer": {"stem_trim": 1, "max_suffix_length": 4},
+    "default": true
+  },
+  {
+    "name": "English",
+    "wiki_section": "English",
+    "iso639_3": "eng",
+    "translation_languages": ["rus", "ukr"],
+    "tokenizer": {"word_characters": "'", "stem_trim": 1, "max_suffix_length": 3}
+  },
+  {
+    "name": "German",
+    "wiki_section": "German",
+    "iso639_3": "deu",
+    "translation_languages": ["eng", "rus", "ukr"],
+    "tokenizer": {"stem_trim": 1, "max_suffix_length": 3}
+  }
+]
+`
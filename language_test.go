@@ -0,0 +1,158 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadLanguages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "language")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	l, err := LoadLanguages("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.Names(), []string{"English", "German", "Hungarian"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("built-in languages: got %q; want %q", got, want)
+	}
+	if got := l.Default().Name; got != "Hungarian" {
+		t.Errorf("built-in default language: got %q; want Hungarian", got)
+	}
+
+	path := filepath.Join(dir, "languages.json")
+	data := `[
+		{"name": "Spanish", "iso639_3": "spa", "translation_languages": ["eng"]},
+		{"name": "Finnish", "wiki_section": "Finnish", "iso639_3": "fin", "tokenizer": {"stem_trim": 2}}
+	]`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err = LoadLanguages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.Names(), []string{"Finnish", "Spanish"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	// The first language is the default one if none is marked.
+	if got := l.Default().Name; got != "Spanish" {
+		t.Errorf("default language: got %q; want Spanish", got)
+	}
+	settings, err := NewSettingsConfig(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.Languages = l
+	s, err := settings.Get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.InputLanguage != "Spanish" || s.InputLanguageISO639_3 != "spa" || !reflect.DeepEqual(s.TranslationLanguages, map[string]bool{"eng": true}) {
+		t.Errorf("settings of a new user: got %q (%q) with translations %v; want Spanish (spa) with [eng]", s.InputLanguage, s.InputLanguageISO639_3, s.TranslationLanguages)
+	}
+	// Missing fields are filled in.
+	if got := l.WikiSection("Spanish"); got != "Spanish" {
+		t.Errorf("WikiSection(Spanish): got %q; want Spanish", got)
+	}
+	want := Tokenizer{StemTrim: 2, MaxSuffixLength: defaultTokenizer.MaxSuffixLength}
+	if got := l.Tokenizer("Finnish"); got != want {
+		t.Errorf("Tokenizer(Finnish): got %+v; want %+v", got, want)
+	}
+	if got := l.Tokenizer("Hungarian"); got != defaultTokenizer {
+		t.Errorf("Tokenizer of unknown language: got %+v; want %+v", got, defaultTokenizer)
+	}
+
+	for _, bad := range []string{
+		`[]`,
+		`[{"iso639_3": "spa"}]`,
+		`[{"name": "Spanish", "iso639_3": "es"}]`,
+		`[{"name": "Spanish", "iso639_3": "spa", "translation_languages": ["English"]}]`,
+		`[{"name": "Spanish", "iso639_3": "spa"}, {"name": "Spanish", "iso639_3": "spa"}]`,
+		`[{"name": "Spanish", "iso639_3": "spa", "tokenizer": {"stem_trim": -1}}]`,
+		`[{"name": "Spanish", "iso639_3": "spa", "default": true}, {"name": "Finnish", "iso639_3": "fin", "default": true}]`,
+	} {
+		if _, err := ParseLanguages([]byte(bad)); err == nil {
+			t.Errorf("ParseLanguages(%s): got nil error; want error", bad)
+		}
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "language")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	settings, err := NewSettingsConfig(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 0
+	s := DefaultSettings()
+	// User chose to see Polish translations and not English or Ukrainian ones.
+	s.TranslationLanguages = map[string]bool{"eng": false, "pol": true, "ukr": false}
+	if err := settings.Set(chatID, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.SetLanguage(chatID, "English"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := settings.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.InputLanguage != "English" || got.InputLanguageISO639_3 != "eng" {
+		t.Errorf("got language %q (%q); want English (eng)", got.InputLanguage, got.InputLanguageISO639_3)
+	}
+	// English sentences aren't translated into English.
+	want := map[string]bool{"pol": true, "rus": true, "ukr": false}
+	if !reflect.DeepEqual(got.Translations(), want) {
+		t.Errorf("got translation languages %v; want %v", got.Translations(), want)
+	}
+
+	// Choices are kept when switching back.
+	if err := settings.SetLanguage(chatID, "Hungarian"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = settings.Get(chatID); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]bool{"eng": false, "pol": true, "rus": true, "ukr": false}
+	if !reflect.DeepEqual(got.Translations(), want) {
+		t.Errorf("got translation languages after switching back %v; want %v", got.Translations(), want)
+	}
+	if err := settings.SetLanguage(chatID, "Spanish"); err == nil {
+		t.Errorf("SetLanguage(Spanish): got nil error; want error")
+	}
+}
+
+func TestTokenizerWordCharacters(t *testing.T) {
+	english := Tokenizer{WordCharacters: "'", StemTrim: 1, MaxSuffixLength: 3}
+	got, forms := english.blankWord("I don't know.", "don't")
+	if want := "I _____ know."; got != want || !reflect.DeepEqual(forms, []string{"don't"}) {
+		t.Errorf("blankWord: got %q, %q; want %q, [don't]", got, forms, want)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Leeches are words which are forgotten over and over again.
+package main
+
+import "fmt"
+
+const (
+	// Number of lapses after which the word becomes a leech.
+	leechLapses = 8
+	// Leeches which weren't suspended are reported again every
+	// leechRepeatLapses lapses.
+	leechRepeatLapses = leechLapses / 2
+)
+
+// IsLeech returns true if the user should be told that the word with the
+// number of lapses is a leech.
+func IsLeech(lapses int) bool {
+	if lapses < leechLapses {
+		return false
+	}
+	return (lapses-leechLapses)%leechRepeatLapses == 0
+}
+
+// leechReply tells the user that the word is a leech and offers to deal with
+// it.
+func leechReply(s *State, chatID int64, word string, lapses int) error {
+	return s.Telegram.SendMessage(NewMessageReply(chatID,
+		fmt.Sprintf("%q is a leech: you have forgotten it %d times. "+
+			"Consider suspending it or editing it, e.g. adding a mnemonic.", word, lapses),
+		[]Callback{SuspendCallback{Word: word}, EditCallback{word}, KeepLeechCallback{}}))
+}
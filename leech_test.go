@@ -0,0 +1,141 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsLeech(t *testing.T) {
+	var got []int
+	for l := 0; l <= 20; l++ {
+		if IsLeech(l) {
+			got = append(got, l)
+		}
+	}
+	want := []int{8, 12, 16, 20}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got leeches at %v lapses; want %v", got, want)
+	}
+}
+
+func TestLeech(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leech")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRepetition(filepath.Join(dir, "tmpdb"), []time.Duration{0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"fut", "kutya"} {
+		if err := r.Save(chatID, "", w, w+" is something"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, legacy := r.Scheduler(LegacyScheduler)
+	var c Card
+	for i := 0; i < leechLapses; i++ {
+		if _, err := r.AnswerGrade(chatID, "fut", Forward, GradeGood, legacy, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		if c, err = r.AnswerGrade(chatID, "fut", Forward, GradeAgain, legacy, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Lapses != leechLapses || !IsLeech(c.Lapses) {
+		t.Errorf("got %d lapses; want leech with %d", c.Lapses, leechLapses)
+	}
+
+	// Words which are never learned become leeches too.
+	for i := 0; i < leechLapses; i++ {
+		if c, err = r.AnswerGrade(chatID, "kutya", Forward, GradeAgain, legacy, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Stage != 0 || c.Lapses != leechLapses || !IsLeech(c.Lapses) {
+		t.Errorf("got %d lapses at stage %d; want leech with %d at stage 0", c.Lapses, c.Stage, leechLapses)
+	}
+
+	if err := r.Suspend(chatID, "fut", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.DueWords(chatID, Forward, Scope{}); err != nil || !reflect.DeepEqual(got, []string{"kutya"}) {
+		t.Errorf("DueWords after suspend: %q, %v want [kutya], nil", got, err)
+	}
+	if err := r.Suspend(chatID, "fut", false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.DueWords(chatID, Forward, Scope{}); err != nil || len(got) != 2 {
+		t.Errorf("DueWords after unsuspend: %q, %v want both words", got, err)
+	}
+}
+
+func TestResetProgressIsNotLapse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leech")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	if err := c.Repetitions.Save(chatID, "", "fut", "fut\n\nto run"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < leechLapses; i++ {
+		m := &Message{Text: "fut"}
+		m.Chat.Id = chatID
+		if err := c.Update(&Update{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+		if err := fk.PressButton("Reset progress"); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.PollAndProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range fk.messages {
+		if strings.Contains(m.Text, "is a leech") {
+			t.Errorf("got message %q; want no leech notice after resets", m.Text)
+		}
+	}
+	if h, err := c.Repetitions.History(chatID, "fut", Forward); err != nil || len(h) != 0 {
+		t.Errorf("History: got %+v, %v; want no reviews", h, err)
+	}
+	rs, err := c.Repetitions.Records(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("got %d words; want fut only", len(rs))
+	}
+	if s := rs[0].Schedule; s.Lapses != 0 || s.Stage != 0 {
+		t.Errorf("got %d lapses at stage %d; want none at stage 0", s.Lapses, s.Stage)
+	}
+}
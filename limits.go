@@ -0,0 +1,140 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Daily limits on the number of new words and reviews.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNewCardsPerDay = 20
+	defaultReviewsPerDay  = 200
+)
+
+// DailyLimits is the maximum number of words practiced per day. 0 means no
+// limit.
+type DailyLimits struct {
+	NewCards int
+	Reviews  int
+}
+
+func (l DailyLimits) String() string {
+	limit := func(n int, what string) string {
+		if n == 0 {
+			return "unlimited " + what
+		}
+		return fmt.Sprintf("%d %s", n, what)
+	}
+	return limit(l.NewCards, "new words") + ", " + limit(l.Reviews, "reviews")
+}
+
+// ParseDailyLimits parses limits of new words and reviews, e.g. "20 200".
+func ParseDailyLimits(s string) (DailyLimits, error) {
+	fs := strings.Fields(s)
+	if len(fs) != 2 {
+		return DailyLimits{}, fmt.Errorf("expected 2 numbers, got %q", s)
+	}
+	var ns []int
+	for _, f := range fs {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return DailyLimits{}, fmt.Errorf("%q isn't a non-negative number", f)
+		}
+		ns = append(ns, n)
+	}
+	return DailyLimits{NewCards: ns[0], Reviews: ns[1]}, nil
+}
+
+// newCondition returns condition on the Repetition table which is true for
+// words which were never practiced in the direction.
+func newCondition(dir Direction) string {
+	// Only constants are put in the condition, so there is no risk of sql
+	// injection.
+	return fmt.Sprintf(`(%sstage = 0
+		    AND NOT EXISTS (
+			SELECT 1
+			FROM Reviews
+			WHERE Reviews.chat_id = Repetition.chat_id
+			  AND Reviews.word = Repetition.word
+			  AND Reviews.direction = %d))`, dir.prefix(), dir)
+}
+
+// StudiedToday returns the number of words practiced for the first time since
+// the start of the day, and the number of reviews of other words.
+func (r *Repetition) StudiedToday(chatID int64, dayStart time.Time) (newCards, reviews int, err error) {
+	rows, err := r.db.Query(`
+		SELECT MIN(reviewed_seconds), SUM(reviewed_seconds >= ?)
+		FROM Reviews
+		WHERE chat_id = ?
+		GROUP BY word, direction`,
+		dayStart.Unix(), chatID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("INTERNAL: retrieving reviews for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var first int64
+		var today int
+		if err := rows.Scan(&first, &today); err != nil {
+			return 0, 0, err
+		}
+		if first >= dayStart.Unix() {
+			newCards++
+		} else {
+			reviews += today
+		}
+	}
+	return newCards, reviews, rows.Err()
+}
+
+// limitScope excludes new words or reviews from the scope once the daily
+// limits are reached.
+func limitScope(r *Repetition, chatID int64, settings *Settings, scope Scope, now time.Time) (Scope, error) {
+	l := settings.DailyLimits()
+	if l.NewCards == 0 && l.Reviews == 0 {
+		return scope, nil
+	}
+	newCards, reviews, err := r.StudiedToday(chatID, startOfDay(now, settings.Location()))
+	if err != nil {
+		return scope, err
+	}
+	scope.NoNew = l.NewCards > 0 && newCards >= l.NewCards
+	scope.NoReviews = l.Reviews > 0 && reviews >= l.Reviews
+	return scope, nil
+}
+
+// doneReply tells the user that there is nothing left to practice today and
+// how many words were deferred due to daily limits.
+func doneReply(s *State, chatID int64, dir Direction, scope Scope) error {
+	deferred := 0
+	if scope.NoNew || scope.NoReviews {
+		scope.NoNew, scope.NoReviews = false, false
+		ws, err := s.Repetitions.DueWords(chatID, dir, scope)
+		if err != nil {
+			return err
+		}
+		deferred = len(ws)
+	}
+	if deferred == 0 {
+		return s.Telegram.SendTextMessage(chatID, "No more rows to practice; exiting practice mode.")
+	}
+	return s.Telegram.SendTextMessage(chatID, fmt.Sprintf(
+		"You are done for today! Daily limits were reached, %d words were deferred. Limits can be changed with /dailylimits.", deferred))
+}
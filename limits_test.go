@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDailyLimits(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    DailyLimits
+		wantErr bool
+	}{
+		{in: "20 200", want: DailyLimits{20, 200}},
+		{in: " 0\t10 ", want: DailyLimits{0, 10}},
+		{in: "20", wantErr: true},
+		{in: "20 -1", wantErr: true},
+		{in: "a b", wantErr: true},
+	} {
+		got, err := ParseDailyLimits(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("ParseDailyLimits(%q): %v, %v want %v, error %t", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestDailyLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRepetition(filepath.Join(dir, "tmpdb"), []time.Duration{0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"fut", "kutya", "ló", "víz"} {
+		if err := r.Save(chatID, "", w, w+" is something"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	// víz was practiced yesterday, so today it's a review.
+	if err := r.AddReviews(chatID, "víz", Forward, []Review{{Time: now.Add(-48 * time.Hour), Grade: GradeGood, Latency: -1}}); err != nil {
+		t.Fatal(err)
+	}
+	_, legacy := r.Scheduler(LegacyScheduler)
+	for _, w := range []string{"fut", "kutya", "víz", "víz"} {
+		if _, err := r.AnswerGrade(chatID, w, Forward, GradeAgain, legacy, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	settings := DefaultSettings()
+	dayStart := startOfDay(now, settings.Location())
+	if n, rv, err := r.StudiedToday(chatID, dayStart); err != nil || n != 2 || rv != 2 {
+		t.Errorf("StudiedToday: %d, %d, %v want 2, 2, nil", n, rv, err)
+	}
+
+	for _, tc := range []struct {
+		limits DailyLimits
+		want   []string
+	}{
+		{DailyLimits{0, 0}, []string{"fut", "kutya", "ló", "víz"}},
+		{DailyLimits{2, 0}, []string{"fut", "kutya", "víz"}},
+		{DailyLimits{3, 2}, []string{"ló"}},
+		{DailyLimits{2, 2}, nil},
+	} {
+		settings.NewCardsPerDay, settings.ReviewsPerDay = tc.limits.NewCards, tc.limits.Reviews
+		scope, err := limitScope(r, chatID, settings, Scope{}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := r.DueWords(chatID, Forward, scope); err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("DueWords with limits %v: %q, %v want %q, nil", tc.limits, got, err, tc.want)
+		}
+	}
+}
@@ -18,6 +18,9 @@ import (
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -32,10 +35,14 @@ func Start(ctx context.Context, opts *CommanderOptions) error {
 	if err != nil {
 		return err
 	}
+	// Reminders stop once the bot stops.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.StartReminders(ctx)
 	if opts.push {
-		return c.StartPush(opts)
+		return c.StartPush(ctx, opts)
 	} else {
-		return c.StartPoll()
+		return c.StartPoll(ctx)
 	}
 }
 
@@ -49,18 +56,28 @@ func main() {
 	port := flag.Int("port", 8443, "Port of which webhook should listen. Needed only if push is set to true.")
 	cert := flag.String("cert_path", "webhook.crt", "TLS certificate. Needed only if push is set to true.")
 	key := flag.String("key_path", "webhook.key", "Private key for TLS. Needed only if push is set to true.")
+	scheduler := flag.String("scheduler", LegacyScheduler, "Default spaced repetition scheduler: legacy (fixed stages), sm2 (adaptive, SuperMemo 2) or fsrs (adaptive, models memory stability). Users can pick their own with /scheduler.")
+	languages := flag.String("languages_path", "", "JSON file with supported input languages. Built-in Hungarian, English and German are used if empty.")
 
 	flag.Parse()
 	log.Printf("db_path: %q", *db)
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		log.Printf("Received %v, shutting down", <-sig)
+		cancel()
+	}()
 	opts := &CommanderOptions{
-		useCache: false,
-		dbPath:   *db,
-		port:     *port,
-		certPath: *cert,
-		keyPath:  *key,
-		ip:       *ip,
-		push:     *push,
+		useCache:      false,
+		dbPath:        *db,
+		port:          *port,
+		certPath:      *cert,
+		keyPath:       *key,
+		ip:            *ip,
+		push:          *push,
+		scheduler:     *scheduler,
+		languagesPath: *languages,
 		stages: []time.Duration{
 			20 * time.Second,
 			1 * time.Hour * 23,
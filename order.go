@@ -0,0 +1,131 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Policies of choosing the next word out of all due words.
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// OrderOverdue practices the most overdue words first.
+	OrderOverdue = "overdue"
+	// OrderStage practices words with the lowest stage first.
+	OrderStage = "stage"
+	// OrderRandom practices words in random order.
+	OrderRandom = "random"
+	// OrderInterleaved practices a new word after every few reviews.
+	OrderInterleaved = "interleaved"
+
+	defaultNewEvery = 5
+)
+
+var OrderPolicies = []string{OrderOverdue, OrderStage, OrderRandom, OrderInterleaved}
+
+// Order of practicing due words. Zero value is OrderOverdue.
+type Order struct {
+	Policy string
+	// Seed of OrderRandom, the same seed gives the same order.
+	Seed int64
+	// OrderInterleaved practices a new word after every NewEvery reviews.
+	NewEvery int
+}
+
+// ParseOrder parses order in the format "policy", or "interleaved N".
+func ParseOrder(s string) (Order, error) {
+	fs := strings.Fields(s)
+	if len(fs) == 0 {
+		return Order{}, fmt.Errorf("order can't be empty")
+	}
+	o := Order{Policy: fs[0]}
+	switch {
+	case o.Policy == OrderInterleaved && len(fs) <= 2:
+		o.NewEvery = defaultNewEvery
+		if len(fs) == 2 {
+			n, err := strconv.Atoi(fs[1])
+			if err != nil || n < 1 {
+				return Order{}, fmt.Errorf("%q isn't a positive number", fs[1])
+			}
+			o.NewEvery = n
+		}
+	case len(fs) > 1:
+		return Order{}, fmt.Errorf("unexpected %q after %q", strings.Join(fs[1:], " "), o.Policy)
+	case o.Policy != OrderOverdue && o.Policy != OrderStage && o.Policy != OrderRandom:
+		return Order{}, fmt.Errorf("unsupported order %q", o.Policy)
+	}
+	return o, nil
+}
+
+func (o Order) String() string {
+	switch o.Policy {
+	case "":
+		return OrderOverdue
+	case OrderInterleaved:
+		return fmt.Sprintf("%s %d", o.Policy, o.NewEvery)
+	}
+	return o.Policy
+}
+
+// dueWord is a candidate for practice.
+type dueWord struct {
+	Word  string
+	Due   int64
+	Stage int
+	// True if the word was never practiced.
+	New bool
+}
+
+// arrange sorts ws in the order they should be practiced. reviews is the
+// number of reviews since the last new word, used by OrderInterleaved, so only
+// the first word of the interleaved order is exact.
+func (o Order) arrange(ws []dueWord, reviews int) []dueWord {
+	// Ties are broken by the word, so that the order doesn't depend on the
+	// order of rows.
+	sort.Slice(ws, func(i, j int) bool {
+		if ws[i].Due != ws[j].Due {
+			return ws[i].Due < ws[j].Due
+		}
+		return ws[i].Word < ws[j].Word
+	})
+	switch o.Policy {
+	case OrderStage:
+		sort.SliceStable(ws, func(i, j int) bool {
+			return ws[i].Stage < ws[j].Stage
+		})
+	case OrderRandom:
+		rand.New(rand.NewSource(o.Seed)).Shuffle(len(ws), func(i, j int) {
+			ws[i], ws[j] = ws[j], ws[i]
+		})
+	case OrderInterleaved:
+		var news, old []dueWord
+		for _, w := range ws {
+			if w.New {
+				news = append(news, w)
+			} else {
+				old = append(old, w)
+			}
+		}
+		if len(news) > 0 && (len(old) == 0 || reviews >= o.NewEvery) {
+			return append(news, old...)
+		}
+		return append(old, news...)
+	}
+	return ws
+}
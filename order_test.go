@@ -0,0 +1,154 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseOrder(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    Order
+		wantErr bool
+	}{
+		{in: "overdue", want: Order{Policy: OrderOverdue}},
+		{in: "random", want: Order{Policy: OrderRandom}},
+		{in: "interleaved", want: Order{Policy: OrderInterleaved, NewEvery: defaultNewEvery}},
+		{in: " interleaved 3 ", want: Order{Policy: OrderInterleaved, NewEvery: 3}},
+		{in: "interleaved 0", wantErr: true},
+		{in: "stage 3", wantErr: true},
+		{in: "alphabetical", wantErr: true},
+		{in: "", wantErr: true},
+	} {
+		got, err := ParseOrder(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("ParseOrder(%q): %+v, %v want %+v, error %t", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestRepeatWordOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRepetition(filepath.Join(dir, "tmpdb"), []time.Duration{0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	now := time.Now()
+	// Words are saved in the order which none of the policies follows.
+	for _, w := range []struct {
+		word  string
+		stage int
+		// How long ago the word was practiced.
+		ago time.Duration
+	}{
+		{"fut", 1, 2 * time.Hour},
+		{"kutya", 2, 3 * time.Hour},
+		{"ló", 0, time.Hour},
+		{"víz", 0, 4 * time.Hour},
+	} {
+		if err := r.Save(chatID, "", w.word, w.word+" is something"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.db.Exec(`
+			UPDATE Repetition
+			SET stage = $0,
+				last_updated_seconds = $1,
+				due_seconds = $1 + interval_seconds
+			WHERE word = $2`,
+			w.stage, now.Add(-w.ago).Unix(), w.word); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only víz is new.
+	for _, w := range []string{"fut", "kutya", "ló"} {
+		if err := r.AddReviews(chatID, w, Forward, []Review{{Time: now.Add(-48 * time.Hour), Grade: GradeGood, Latency: -1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repeat := func(o Order) string {
+		t.Helper()
+		w, err := r.RepeatWord(chatID, Forward, Scope{Order: o})
+		if err != nil {
+			t.Fatalf("RepeatWord(%v): %v", o, err)
+		}
+		return w
+	}
+	for _, tc := range []struct {
+		order Order
+		want  string
+	}{
+		{Order{}, "víz"},
+		{Order{Policy: OrderOverdue}, "víz"},
+		{Order{Policy: OrderStage}, "víz"},
+		{Order{Policy: OrderInterleaved, NewEvery: 2}, "kutya"},
+	} {
+		if got := repeat(tc.order); got != tc.want {
+			t.Errorf("RepeatWord(%v): got %q; want %q", tc.order, got, tc.want)
+		}
+		// Practice modes which can skip words get all of them in order.
+		if got, err := r.OrderedDueWords(chatID, Forward, Scope{Order: tc.order}); err != nil || len(got) != 4 || got[0] != tc.want {
+			t.Errorf("OrderedDueWords(%v): got %q, %v; want 4 words starting with %q", tc.order, got, err, tc.want)
+		}
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		o := Order{Policy: OrderRandom, Seed: seed}
+		if w1, w2 := repeat(o), repeat(o); w1 != w2 {
+			t.Errorf("RepeatWord(%v): got %q and %q; want the same word", o, w1, w2)
+		}
+	}
+
+	// víz isn't the most overdue anymore.
+	if _, err := r.db.Exec(`
+		UPDATE Repetition
+		SET last_updated_seconds = $0,
+			due_seconds = $0 + interval_seconds
+		WHERE word = "víz"`,
+		now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	// Words of the same stage are ordered by how overdue they are.
+	if got := repeat(Order{Policy: OrderStage}); got != "ló" {
+		t.Errorf("RepeatWord(stage): got %q; want ló", got)
+	}
+	if got := repeat(Order{Policy: OrderOverdue}); got != "kutya" {
+		t.Errorf("RepeatWord(overdue): got %q; want kutya", got)
+	}
+	// After 2 reviews a new word is interleaved.
+	interleaved := Order{Policy: OrderInterleaved, NewEvery: 2}
+	if err := r.AddReviews(chatID, "fut", Forward, []Review{{Time: now.Add(-time.Minute), Grade: GradeGood, Latency: -1}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := repeat(interleaved); got != "kutya" {
+		t.Errorf("RepeatWord(%v) after 1 review: got %q; want kutya", interleaved, got)
+	}
+	if err := r.AddReviews(chatID, "ló", Forward, []Review{{Time: now.Add(-time.Minute), Grade: GradeGood, Latency: -1}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := repeat(interleaved); got != "víz" {
+		t.Errorf("RepeatWord(%v) after 2 reviews: got %q; want víz", interleaved, got)
+	}
+}
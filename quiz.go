@@ -0,0 +1,212 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Multiple choice practice, where user picks definition of the word.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Number of options shown in a quiz, including the correct one.
+const quizOptions = 4
+
+// Maximum length of a definition shown as a quiz option.
+const quizOptionLength = 120
+
+// Matches numbered definition lines produced by Definer.Define, e.g.
+// "1. [noun] black". Markdown asterisks might be present if definition is
+// stored before rendering.
+var definitionLineRe = regexp.MustCompile(`^\d+\\?\.\s*\\?\[\*?([^\]*\\]+)\*?\\?\]\s*(.*)$`)
+
+// quizOption is the first definition of the word with the word masked.
+type quizOption struct {
+	Word       string
+	SpeechPart string
+	Text       string
+}
+
+// newQuizOption extracts the first definition of the word.
+func newQuizOption(word, definition string) quizOption {
+	o := quizOption{Word: word}
+	for _, l := range strings.Split(maskWord(word, definition), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if m := definitionLineRe.FindStringSubmatch(l); m != nil {
+			o.SpeechPart = m[1]
+			l = m[2]
+		}
+		o.Text = l
+		break
+	}
+	if r := []rune(o.Text); len(r) > quizOptionLength {
+		o.Text = string(r[:quizOptionLength-1]) + "…"
+	}
+	return o
+}
+
+// pickDistractors returns up to n other words preferring the ones with the
+// same part of speech as the word.
+func pickDistractors(word string, definitions map[string]string, n int, rnd *rand.Rand) []quizOption {
+	want := newQuizOption(word, definitions[word])
+	var same, other []quizOption
+	for w, d := range definitions {
+		if w == word {
+			continue
+		}
+		o := newQuizOption(w, d)
+		if o.Text == "" || o.Text == want.Text {
+			continue
+		}
+		if o.SpeechPart != "" && o.SpeechPart == want.SpeechPart {
+			same = append(same, o)
+		} else {
+			other = append(other, o)
+		}
+	}
+	// Map iteration order is random, but not uniformly so.
+	for _, opts := range [][]quizOption{same, other} {
+		sort.Slice(opts, func(i, j int) bool { return opts[i].Word < opts[j].Word })
+		rnd.Shuffle(len(opts), func(i, j int) { opts[i], opts[j] = opts[j], opts[i] })
+	}
+	ds := append(same, other...)
+	if len(ds) > n {
+		ds = ds[:n]
+	}
+	return ds
+}
+
+// quizCommand asks the user to pick the definition of the word. The correct
+// option is kept here rather than in the callback data, which any client can
+// read.
+type quizCommand struct {
+	name string
+	// Word that is being asked, empty if none.
+	word string
+	// Number of the correct option, starting from 1.
+	correct int
+}
+
+// Make sure all fields are Public, otherwise encoding will not work
+type quizCommandSerialized struct {
+	Word    string
+	Correct int
+}
+
+func (c *quizCommand) Serialize() *SerializedCommand {
+	cs := &quizCommandSerialized{
+		Word:    c.word,
+		Correct: c.correct,
+	}
+	b, err := json.Marshal(cs)
+	if err != nil {
+		log.Printf("INTERNAL ERROR: Couldn't serialize %v: %v", cs, err)
+	}
+	return &SerializedCommand{
+		Name: c.name,
+		Data: b,
+	}
+}
+
+func (c *quizCommand) Init(s *SerializedCommand) error {
+	cs := &quizCommandSerialized{}
+	if err := json.Unmarshal(s.Data, cs); err != nil {
+		return fmt.Errorf("Unmarshal(%s): %w", s.Data, err)
+	}
+	c.word = cs.Word
+	c.correct = cs.Correct
+	return nil
+}
+
+func (c *quizCommand) OnCommand(s *State, m *Message) (Command, error) {
+	return c.askNext(s, m.Chat.Id)
+}
+
+// ProcessMessage handles the message as usual, the quiz is answered with the
+// buttons and goes on.
+func (c *quizCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	_, err := CommandsTemplate.DefaultCommand("").ProcessMessage(s, m)
+	return c, err
+}
+
+// askNext sends a word with several definitions to choose from.
+func (c *quizCommand) askNext(s *State, chatID int64) (Command, error) {
+	scope, err := s.scope(chatID)
+	if err != nil {
+		return nil, err
+	}
+	word, err := s.Repetitions.RepeatWord(chatID, Forward, scope)
+	if err == sql.ErrNoRows {
+		return nil, s.Telegram.SendTextMessage(chatID, "No more rows to practice; exiting practice mode.")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retrieving word for repetition: %w", err)
+	}
+	defs, err := s.Repetitions.Definitions(chatID)
+	if err != nil {
+		return nil, err
+	}
+	rnd := rand.New(rand.NewSource(rand.Int63()))
+	opts := pickDistractors(word, defs, quizOptions-1, rnd)
+	if len(opts) == 0 {
+		return nil, UserError{ChatID: chatID, Err: fmt.Errorf("Quiz needs at least 2 words with different definitions saved for learning.")}
+	}
+	correct := rnd.Intn(len(opts) + 1)
+	opts = append(opts, quizOption{})
+	copy(opts[correct+1:], opts[correct:])
+	opts[correct] = newQuizOption(word, defs[word])
+
+	text := word + "\n"
+	var cs []Callback
+	for i, o := range opts {
+		text += fmt.Sprintf("\n%d. %s", i+1, o.Text)
+		cs = append(cs, QuizCallback{Option: i + 1})
+	}
+	if err := s.Telegram.SendMessage(NewMessageReply(chatID, text, cs)); err != nil {
+		return nil, err
+	}
+	c.word = word
+	c.correct = correct + 1
+	return c, nil
+}
+
+// loadQuiz returns the quiz of the chat, nil if the chat isn't in one.
+func loadQuiz(s *State, chatID int64) (*quizCommand, error) {
+	sc, err := s.LoadCommand(chatID)
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := sc.AsCommand()
+	if err != nil {
+		return nil, err
+	}
+	c, _ := cmd.(*quizCommand)
+	return c, nil
+}
+
+func QuizCommandFactory() CommandFactory {
+	return func(name string) Command {
+		return &quizCommand{name: name}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNewQuizOption(t *testing.T) {
+	for _, tc := range []struct {
+		word, definition string
+		want             quizOption
+	}{
+		{"fekete", "fekete\n\n1. [adjective] black, fekete color\n2. [noun] black", quizOption{"fekete", "adjective", "black, ******** color"}},
+		{"fekete", "*fekete*\n\n1\\. \\[*adjective*\\] black", quizOption{"fekete", "adjective", "black"}},
+		{"foo", "foo is bar", quizOption{"foo", "", "******** is bar"}},
+	} {
+		if got := newQuizOption(tc.word, tc.definition); got != tc.want {
+			t.Errorf("newQuizOption(%q, %q) = %+v; want %+v", tc.word, tc.definition, got, tc.want)
+		}
+	}
+}
+
+func TestPickDistractors(t *testing.T) {
+	defs := map[string]string{
+		"fekete": "fekete\n\n1. [adjective] black",
+		"fehér":  "fehér\n\n1. [adjective] white",
+		"piros":  "piros\n\n1. [adjective] red",
+		"kutya":  "kutya\n\n1. [noun] dog",
+		"macska": "macska\n\n1. [noun] cat",
+		"fut":    "fut\n\n1. [verb] to run",
+		"szén":   "szén\n\n1. [adjective] black",
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		got := pickDistractors("fekete", defs, 3, rand.New(rand.NewSource(seed)))
+		if len(got) != 3 {
+			t.Fatalf("got %d distractors; want 3", len(got))
+		}
+		// Both other adjectives first, "szén" has the same definition.
+		for i, o := range got[:2] {
+			if o.SpeechPart != "adjective" || o.Word == "szén" {
+				t.Errorf("seed %d: distractor %d = %+v; want another adjective", seed, i, o)
+			}
+		}
+		if got[2].SpeechPart == "adjective" {
+			t.Errorf("seed %d: distractor 2 = %+v; want not an adjective", seed, got[2])
+		}
+	}
+	if got := pickDistractors("fekete", map[string]string{"fekete": defs["fekete"]}, 3, rand.New(rand.NewSource(0))); len(got) != 0 {
+		t.Errorf("got %+v; want no distractors", got)
+	}
+}
+
+func TestQuizCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quiz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	for w, d := range map[string]string{
+		"fekete": "fekete\n\n1. [adjective] black",
+		"kutya":  "kutya\n\n1. [noun] dog",
+	} {
+		if err := c.Repetitions.Save(chatID, "", w, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &Message{Text: "/quiz"}
+	m.Chat.Id = chatID
+	if err := c.Update(&Update{Message: m}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &State{c.Clients}
+	quiz, err := loadQuiz(s, chatID)
+	if err != nil || quiz == nil {
+		t.Fatalf("loadQuiz: got %v, %v; want the quiz", quiz, err)
+	}
+	// The answer is only known to the bot.
+	lm := fk.messages[len(fk.messages)-1]
+	for _, ks := range lm.ReplyMarkup.InlineKeyboard {
+		for _, k := range ks {
+			if len(k.CallbackData) > 64 || strings.Contains(k.CallbackData, quiz.word) || strings.Contains(k.CallbackData, "Grade") {
+				t.Errorf("got callback data %q; want at most 64 bytes without the word or grade", k.CallbackData)
+			}
+		}
+	}
+
+	if err := fk.PressButton(fmt.Sprint(quiz.correct)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PollAndProcess(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := c.Repetitions.History(chatID, quiz.word, Forward)
+	if err != nil || len(h) != 1 || h[0].Grade != GradeGood {
+		t.Errorf("History(%q) after the correct option: got %+v, %v; want a single good review", quiz.word, h, err)
+	}
+	// The other word is asked next.
+	next, err := loadQuiz(s, chatID)
+	if err != nil || next == nil || next.word == quiz.word {
+		t.Errorf("loadQuiz after answer: got %+v, %v; want the other word", next, err)
+	}
+}
@@ -17,19 +17,39 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
+	"unicode"
+)
+
+const (
+	// How often Reminder checks whether chats should be reminded.
+	reminderCheckPeriod = time.Minute
+	maxRemindersPerDay  = 24
+	// Reminders are sent from 08:00 to 21:00 unless the user chose otherwise.
+	defaultRemindFromMinutes = 8 * 60
+	defaultRemindToMinutes   = 21 * 60
+	// How long reminders are muted by the "Mute reminders for a week" button.
+	muteDuration = 7 * 24 * time.Hour
 )
 
 type Notification struct {
 	ChatID int64
+	// Number of words due for practice.
+	Due int
 }
 
 // reminder
 type Reminder struct {
 	sendNofication func(*Notification) error
 	fetchSettings  func() (map[int64]*Settings, error)
+	// countDue returns the number of words the chat can practice at the time
+	// with "Practice now".
+	countDue func(chatID int64, s *Settings, now time.Time) (int, error)
+	// now returns the current time, tests can change it.
+	now func() time.Time
 
-	// db stores last reminder time for each chat ID.
+	// db stores last reminder time and snooze state for each chat ID.
 	db *sql.DB
 }
 
@@ -38,18 +58,62 @@ func NewReminder(c *Clients, db *sql.DB) (*Reminder, error) {
 	if _, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS Reminders (
 			chat_id INTEGER PRIMARY KEY,
-			last_reminder_time_seconds INTEGER -- seconds since UNIX epoch
+			last_reminder_time_seconds INTEGER, -- seconds since UNIX epoch
+			snoozed_until_seconds INTEGER NOT NULL DEFAULT 0
 		);`); err != nil {
 		return nil, err
 	}
+	if err := addMissingColumns(db, "Reminders",
+		"snoozed_until_seconds INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, fmt.Errorf("INTERNAL: migrating Reminders: %w", err)
+	}
 
 	return &Reminder{
-		db: db,
+		db:  db,
+		now: time.Now,
 		sendNofication: func(n *Notification) error {
-			log.Printf("Notification: %v", n)
-			return nil
+			return c.Telegram.SendMessage(&MessageReply{
+				ChatId: n.ChatID,
+				Text:   fmt.Sprintf("You have %d words to practice.", n.Due),
+				ReplyMarkup: &ReplyMarkup{
+					InlineKeyboard: reminderKeyboard(),
+				},
+			})
+		},
+		fetchSettings: func() (map[int64]*Settings, error) {
+			ss, err := c.Settings.GetAll()
+			if err != nil {
+				return nil, err
+			}
+			if ss == nil {
+				ss = make(map[int64]*Settings)
+			}
+			// Chats which never changed settings use the default ones.
+			chats, err := c.Repetitions.Chats()
+			if err != nil {
+				return nil, err
+			}
+			for _, chatID := range chats {
+				if _, ok := ss[chatID]; !ok {
+					ss[chatID] = c.Settings.Default()
+				}
+			}
+			return ss, nil
+		},
+		countDue: func(chatID int64, s *Settings, now time.Time) (int, error) {
+			scope, err := limitScope(c.Repetitions, chatID, s, s.PracticeScope(), now)
+			if err != nil {
+				return 0, err
+			}
+			// Only words which "Practice now" asks are counted. Reverse
+			// practice is opt-in, new words are due in reverse right away
+			// even for chats which never use it.
+			ws, err := c.Repetitions.DueWords(chatID, Forward, scope)
+			if err != nil {
+				return 0, err
+			}
+			return len(ws), nil
 		},
-		fetchSettings: c.Settings.GetAll,
 	}, nil
 }
 
@@ -60,47 +124,108 @@ func (r *Reminder) LastReminderTime(chatID int64) (time.Time, error) {
 		WHERE chat_id = $0`,
 		chatID)
 	var u int64
-	err := row.Scan(&u)
-	if err != nil {
-		u = 0
-		if err != sql.ErrNoRows {
-			err = fmt.Errorf("INTERNAL: retrieving last_reminder_time_seconds for chat id %d: %w", chatID, err)
-		}
+	// Chats which were never reminded don't have a row.
+	if err := row.Scan(&u); err != nil && err != sql.ErrNoRows {
+		return time.Unix(0, 0), fmt.Errorf("INTERNAL: retrieving last_reminder_time_seconds for chat id %d: %w", chatID, err)
 	}
-	return time.Unix(u, 0), err
+	return time.Unix(u, 0), nil
 }
 
-func (r *Reminder) UpdateLastReminderTime(chatID int64) error {
+func (r *Reminder) UpdateLastReminderTime(chatID int64, t time.Time) error {
+	if err := r.ensureChat(chatID); err != nil {
+		return err
+	}
 	_, err := r.db.Exec(`
-		INSERT OR REPLACE INTO Reminders(chat_id, last_reminder_time_seconds) VALUES
-		($0, $1);`,
-		chatID, time.Now().Unix())
+		UPDATE Reminders SET last_reminder_time_seconds = ?
+		WHERE chat_id = ?;`,
+		t.Unix(), chatID)
 	if err != nil {
 		return fmt.Errorf("INTERNAL: Failed updating reminder_time: %w", err)
 	}
 	return nil
 }
 
+// ensureChat adds the row for the chat so that its columns can be updated
+// independently.
+func (r *Reminder) ensureChat(chatID int64) error {
+	if _, err := r.db.Exec(`
+		INSERT OR IGNORE INTO Reminders(chat_id, last_reminder_time_seconds)
+		VALUES (?, 0);`,
+		chatID); err != nil {
+		return fmt.Errorf("INTERNAL: adding reminders for chat %d: %w", chatID, err)
+	}
+	return nil
+}
+
+// SnoozedUntil returns the time until which the chat shouldn't be reminded.
+func (r *Reminder) SnoozedUntil(chatID int64) (time.Time, error) {
+	row := r.db.QueryRow(`
+		SELECT snoozed_until_seconds
+		FROM Reminders
+		WHERE chat_id = ?`,
+		chatID)
+	var u int64
+	if err := row.Scan(&u); err != nil && err != sql.ErrNoRows {
+		return time.Unix(0, 0), fmt.Errorf("INTERNAL: retrieving snoozed_until_seconds for chat id %d: %w", chatID, err)
+	}
+	return time.Unix(u, 0), nil
+}
+
+// Snooze stops reminders for the chat until the given time. If remindAgain is
+// set, the last reminder counts as not sent, so it's repeated once the snooze
+// is over, as long as it's still within the reminder window.
+func (r *Reminder) Snooze(chatID int64, until time.Time, remindAgain bool) error {
+	if err := r.ensureChat(chatID); err != nil {
+		return err
+	}
+	q := `UPDATE Reminders SET snoozed_until_seconds = ? WHERE chat_id = ?;`
+	if remindAgain {
+		q = `UPDATE Reminders SET snoozed_until_seconds = ?, last_reminder_time_seconds = 0 WHERE chat_id = ?;`
+	}
+	if _, err := r.db.Exec(q, until.Unix(), chatID); err != nil {
+		return fmt.Errorf("INTERNAL: snoozing reminders for chat %d: %w", chatID, err)
+	}
+	return nil
+}
+
+// Unsnooze ends the snooze or mute of the chat's reminders.
+func (r *Reminder) Unsnooze(chatID int64) error {
+	return r.Snooze(chatID, time.Unix(0, 0), false)
+}
+
 func (r *Reminder) Loop(ticker <-chan time.Time, cancel <-chan struct{}) {
 	for {
 		cs, err := r.fetchSettings()
 		if err != nil {
 			log.Printf("ERROR: fetchSettings: %v", err)
 		}
-		// TODO: Take into account availability window, reminder frequency and timezone.
-		// newReminderTime = lastReminder + (aval window size)/Frequency
-		for chatID, _ := range cs {
-			const frequency = 1
+		for chatID, settings := range cs {
 			rt, err := r.LastReminderTime(chatID)
 			if err != nil {
 				log.Print(err)
 			}
-			newRT := rt.Add(24 / frequency * time.Hour)
-			if time.Now().After(newRT) {
-				if err := r.sendNofication(&Notification{chatID}); err != nil {
+			snoozed, err := r.SnoozedUntil(chatID)
+			if err != nil {
+				log.Print(err)
+			}
+			now := r.now()
+			if now.Before(snoozed) {
+				continue
+			}
+			if settings.ShouldRemind(rt, now) {
+				due, err := r.countDue(chatID, settings, now)
+				if err != nil {
 					log.Print(err)
+					continue
 				}
-				if err := r.UpdateLastReminderTime(chatID); err != nil {
+				// Chat will be reminded once there are words to practice.
+				if due == 0 {
+					continue
+				}
+				if err := r.sendNofication(&Notification{ChatID: chatID, Due: due}); err != nil {
+					log.Print(err)
+				}
+				if err := r.UpdateLastReminderTime(chatID, now); err != nil {
 					log.Print(err)
 				}
 			}
@@ -112,3 +237,149 @@ func (r *Reminder) Loop(ticker <-chan time.Time, cancel <-chan struct{}) {
 		}
 	}
 }
+
+// reminderKeyboard has buttons to practice right away or to postpone reminders.
+func reminderKeyboard() [][]*InlineKeyboard {
+	return [][]*InlineKeyboard{
+		{PracticeNowCallback{}.AsInlineKeyboard()},
+		{
+			SnoozeCallback{SnoozeHourAction}.AsInlineKeyboard(),
+			SnoozeCallback{SnoozeTomorrowAction}.AsInlineKeyboard(),
+		},
+		{SnoozeCallback{MuteWeekAction}.AsInlineKeyboard()},
+	}
+}
+
+// ParseReminderWindow parses the time of the day when reminders can be sent,
+// e.g. "08:00-21:00", into minutes since midnight. Window can cross midnight,
+// e.g. "20:00-01:00". "all" means the whole day, which is returned as equal
+// minutes.
+func ParseReminderWindow(s string) (from, to int, err error) {
+	if strings.EqualFold(strings.TrimSpace(s), "all") {
+		return 0, 0, nil
+	}
+	ts := strings.Split(strings.TrimSpace(s), "-")
+	if len(ts) != 2 {
+		return 0, 0, fmt.Errorf("expected window in the format HH:MM-HH:MM, got %q", s)
+	}
+	var ms []int
+	for _, t := range ts {
+		p, err := time.Parse("15:04", strings.TrimSpace(t))
+		if err != nil {
+			return 0, 0, fmt.Errorf("%q isn't a time in the format HH:MM", t)
+		}
+		ms = append(ms, p.Hour()*60+p.Minute())
+	}
+	if ms[0] == ms[1] {
+		return 0, 0, fmt.Errorf("window can't be empty, use \"all\" for the whole day")
+	}
+	return ms[0], ms[1], nil
+}
+
+// ParseWeekdays parses days of the week, e.g. "Mon, Wed Fri". "all" means
+// every day, which is returned as nil.
+func ParseWeekdays(s string) ([]time.Weekday, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "all") {
+		return nil, nil
+	}
+	var ds []time.Weekday
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(f, d.String()) || strings.EqualFold(f, d.String()[:3]) {
+				ds = append(ds, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%q isn't a day of the week", f)
+		}
+	}
+	if len(ds) == 0 {
+		return nil, fmt.Errorf("no days of the week")
+	}
+	return ds, nil
+}
+
+// reminderWindow returns the time of the day when reminders can be sent in
+// minutes since midnight.
+func (s *Settings) reminderWindow() (from, to int) {
+	if s.RemindFromMinutes == s.RemindToMinutes {
+		return 0, 24 * 60
+	}
+	return s.RemindFromMinutes, s.RemindToMinutes
+}
+
+func (s *Settings) remindersPerDay() int {
+	if s.RemindersPerDay < 1 {
+		return 1
+	}
+	return s.RemindersPerDay
+}
+
+// ShouldRemind returns true if the user should be reminded at now, given that
+// the last reminder was sent at last. Reminders are spread evenly across the
+// window on the chosen days of the week in the user's time zone. Reminders
+// which were missed aren't sent after the window.
+func (s *Settings) ShouldRemind(last, now time.Time) bool {
+	now = now.In(s.Location())
+	from, to := s.reminderWindow()
+	if to <= from {
+		// Window crosses midnight and ends the next day.
+		to += 24 * 60
+	}
+	// Times are built from the wall clock, so that they stay the same on days
+	// when daylight saving time changes.
+	y, m, d := now.Date()
+	at := func(minutes int) time.Time {
+		return time.Date(y, m, d, 0, minutes, 0, 0, now.Location())
+	}
+	// Window which crosses midnight might have started the day before.
+	if to > 24*60 && at(from).After(now) {
+		d--
+	}
+	if len(s.RemindWeekdays) > 0 {
+		allowed := false
+		for _, wd := range s.RemindWeekdays {
+			allowed = allowed || wd == at(0).Weekday()
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if !now.Before(at(to)) {
+		return false
+	}
+	n := s.remindersPerDay()
+	// The latest reminder time which has passed.
+	var slot time.Time
+	for i := 0; i < n; i++ {
+		t := at(from + i*(to-from)/n)
+		if t.After(now) {
+			break
+		}
+		slot = t
+	}
+	return !slot.IsZero() && last.Before(slot)
+}
+
+// RemindersString describes when reminders are sent and until when they are
+// snoozed if it's after now.
+func (s *Settings) RemindersString(snoozedUntil, now time.Time) string {
+	from, to := s.reminderWindow()
+	days := "every day"
+	if len(s.RemindWeekdays) > 0 {
+		var ds []string
+		for _, d := range s.RemindWeekdays {
+			ds = append(ds, d.String()[:3])
+		}
+		days = strings.Join(ds, " ")
+	}
+	r := fmt.Sprintf("%d per day, %02d:%02d-%02d:%02d, %s",
+		s.remindersPerDay(), from/60, from%60, to/60, to%60, days)
+	if snoozedUntil.After(now) {
+		r += fmt.Sprintf(", snoozed until %s", snoozedUntil.In(s.Location()).Format("Mon Jan 2 15:04"))
+	}
+	return r
+}
@@ -18,6 +18,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -44,18 +47,45 @@ func TestReminders(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	rep, err := NewRepetition(dbPath, []time.Duration{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	r, err := NewReminder(&Clients{
-		Settings: settings,
+		Settings:    settings,
+		Repetitions: rep,
 	}, db)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	const chatID int64 = 0
-	if err := settings.Set(chatID, DefaultSettings()); err != nil {
+	// Only chats with due words are reminded, including the ones which never
+	// changed settings. Words due only in reverse don't count, as reminders
+	// lead to /practice.
+	const chatID, noDueChatID, defaultSettingsChatID, reverseChatID int64 = 0, 1, 2, 3
+	for _, id := range []int64{chatID, noDueChatID} {
+		if err := settings.Set(id, DefaultSettings()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []int64{chatID, defaultSettingsChatID, reverseChatID} {
+		if err := rep.Save(id, "", "fekete", "black"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := rep.db.Exec(`
+		UPDATE Repetition SET due_seconds = ?
+		WHERE chat_id = ?`,
+		time.Now().Add(24*time.Hour).Unix(), reverseChatID); err != nil {
 		t.Fatal(err)
 	}
 
+	// Chats which were never reminded can be reminded right away.
+	if got, err := r.LastReminderTime(chatID); err != nil || got.Unix() != 0 {
+		t.Errorf("LastReminderTime: got %v, %v; want %v, nil", got, err, time.Unix(0, 0))
+	}
+
 	c := make(chan time.Time)
 
 	cancel := make(chan struct{})
@@ -64,6 +94,9 @@ func TestReminders(t *testing.T) {
 		cancel <- struct{}{}
 	}()
 
+	// Default settings remind once a day from 08:00 UTC.
+	r.now = func() time.Time { return time.Date(2020, 5, 4, 12, 0, 0, 0, time.UTC) }
+
 	var sent []*Notification
 	r.sendNofication = func(n *Notification) error {
 		sent = append(sent, n)
@@ -72,7 +105,224 @@ func TestReminders(t *testing.T) {
 
 	r.Loop(c, cancel)
 
-	if len(sent) != 1 {
-		t.Errorf("got %d notifications (%v), want 1", len(sent), sent)
+	sort.Slice(sent, func(i, j int) bool { return sent[i].ChatID < sent[j].ChatID })
+	want := []*Notification{
+		{ChatID: chatID, Due: 1},
+		{ChatID: defaultSettingsChatID, Due: 1},
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("got notifications %+v, want %+v", sent, want)
+	}
+}
+
+func TestReminderSnooze(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "tmpdb")
+
+	settings, err := NewSettingsConfig(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := NewRepetition(dbPath, []time.Duration{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReminder(&Clients{
+		Settings:    settings,
+		Repetitions: rep,
+	}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const chatID int64 = 0
+	if err := rep.Save(chatID, "", "fekete", "black"); err != nil {
+		t.Fatal(err)
+	}
+	sent := 0
+	r.sendNofication = func(n *Notification) error {
+		sent++
+		return nil
+	}
+	// loopAt runs a single iteration of the loop at the given time.
+	loopAt := func(now time.Time) {
+		r.now = func() time.Time { return now }
+		cancel := make(chan struct{})
+		close(cancel)
+		r.Loop(nil, cancel)
+	}
+
+	day := time.Date(2020, 5, 4, 0, 0, 0, 0, time.UTC)
+	loopAt(day.Add(9 * time.Hour))
+	if sent != 1 {
+		t.Fatalf("got %d notifications; want 1", sent)
+	}
+
+	// Snoozed reminder is sent again once the snooze is over.
+	if err := r.Snooze(chatID, day.Add(10*time.Hour), true); err != nil {
+		t.Fatal(err)
+	}
+	loopAt(day.Add(9*time.Hour + 30*time.Minute))
+	if sent != 1 {
+		t.Errorf("got %d notifications while snoozed; want 1", sent)
+	}
+	loopAt(day.Add(10 * time.Hour))
+	if sent != 2 {
+		t.Errorf("got %d notifications after snooze; want 2", sent)
+	}
+
+	// Muted chat isn't reminded on the following days.
+	if err := r.Snooze(chatID, day.Add(muteDuration), false); err != nil {
+		t.Fatal(err)
+	}
+	loopAt(day.Add(24*time.Hour + 9*time.Hour))
+	if sent != 2 {
+		t.Errorf("got %d notifications while muted; want 2", sent)
+	}
+	loopAt(day.Add(muteDuration + 9*time.Hour))
+	if sent != 3 {
+		t.Errorf("got %d notifications after mute; want 3", sent)
+	}
+	if got, err := r.SnoozedUntil(chatID); err != nil || !got.Equal(day.Add(muteDuration)) {
+		t.Errorf("SnoozedUntil: got %v, %v; want %v, nil", got, err, day.Add(muteDuration))
+	}
+	s := DefaultSettings()
+	if got, want := s.RemindersString(day.Add(muteDuration), day), "snoozed until Mon May 11 00:00"; !strings.HasSuffix(got, want) {
+		t.Errorf("RemindersString while muted: got %q; want suffix %q", got, want)
+	}
+
+	// Muted chat is reminded again after unsnoozing.
+	if err := r.Snooze(chatID, day.Add(2*muteDuration), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Unsnooze(chatID); err != nil {
+		t.Fatal(err)
+	}
+	loopAt(day.Add(muteDuration + 24*time.Hour + 9*time.Hour))
+	if sent != 4 {
+		t.Errorf("got %d notifications after unsnooze; want 4", sent)
+	}
+	if got := s.RemindersString(time.Unix(0, 0), day); strings.Contains(got, "snoozed") {
+		t.Errorf("RemindersString after unsnooze: got %q; want no snooze", got)
+	}
+}
+
+func TestReminderSettingsUnsnooze(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	if err := c.Reminders.Snooze(chatID, time.Now().Add(muteDuration), false); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"/reminderdays", "Mon Fri"} {
+		m := &Message{Text: text}
+		m.Chat.Id = chatID
+		if err := c.Update(&Update{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := c.Reminders.SnoozedUntil(chatID); err != nil || got.After(time.Now()) {
+		t.Errorf("SnoozedUntil after /reminderdays: got %v, %v; want past, nil", got, err)
+	}
+}
+
+func TestShouldRemind(t *testing.T) {
+	kyiv := DefaultSettings()
+	kyiv.TimeZone = "UTC+3"
+	kyiv.RemindersPerDay = 2
+	weekend := DefaultSettings()
+	weekend.RemindWeekdays = []time.Weekday{time.Saturday, time.Sunday}
+	wholeDay := &Settings{}
+	night := DefaultSettings()
+	night.RemindFromMinutes, night.RemindToMinutes = 22*60, 2*60
+	night.RemindersPerDay = 2
+	night.RemindWeekdays = []time.Weekday{time.Monday}
+	budapest := DefaultSettings()
+	budapest.TimeZone = "Europe/Budapest"
+	// Clocks in Budapest moved from 02:00 to 03:00 on this Sunday.
+	dstChange := time.Date(2020, 3, 29, 0, 0, 0, 0, time.UTC)
+
+	// Monday.
+	day := time.Date(2020, 5, 4, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+	never := time.Unix(0, 0)
+	for _, tc := range []struct {
+		name      string
+		s         *Settings
+		last, now time.Time
+		want      bool
+	}{
+		{"before window", DefaultSettings(), never, at(7, 59), false},
+		{"window start", DefaultSettings(), never, at(8, 0), true},
+		{"already reminded", DefaultSettings(), at(8, 0), at(20, 0), false},
+		{"reminded yesterday", DefaultSettings(), at(-10, 0), at(9, 0), true},
+		{"after window", DefaultSettings(), never, at(21, 0), false},
+		// 08:00-21:00 in UTC+3 is 05:00-18:00 UTC, second reminder is at 11:30 UTC.
+		{"first in time zone", kyiv, never, at(5, 0), true},
+		{"between reminders", kyiv, at(5, 0), at(11, 29), false},
+		{"second reminder", kyiv, at(5, 0), at(11, 30), true},
+		{"after window in time zone", kyiv, at(5, 0), at(18, 0), false},
+		{"not on weekdays", weekend, never, at(12, 0), false},
+		{"on weekend", weekend, never, at(5*24+12, 0), true},
+		{"whole day", wholeDay, at(-1, 0), at(0, 0), true},
+		// 22:00-02:00 window has reminders at 22:00 and 00:00.
+		{"night window start", night, never, at(22, 0), true},
+		{"night between reminders", night, at(22, 0), at(23, 59), false},
+		{"night after midnight", night, at(22, 0), at(24, 0), true},
+		{"night window end", night, never, at(26, 0), false},
+		{"night window from yesterday", night, never, at(1, 0), false},
+		// 08:00 in Budapest is 06:00 UTC after the change.
+		{"before window on DST change", budapest, never, dstChange.Add(5*time.Hour + 59*time.Minute), false},
+		{"window start on DST change", budapest, never, dstChange.Add(6 * time.Hour), true},
+	} {
+		if got := tc.s.ShouldRemind(tc.last, tc.now); got != tc.want {
+			t.Errorf("%s: ShouldRemind(%v, %v) = %t; want %t", tc.name, tc.last, tc.now, got, tc.want)
+		}
+	}
+}
+
+func TestParseReminderSettings(t *testing.T) {
+	if from, to, err := ParseReminderWindow("08:00-21:30"); err != nil || from != 8*60 || to != 21*60+30 {
+		t.Errorf("ParseReminderWindow: %d, %d, %v want 480, 1290, nil", from, to, err)
+	}
+	if from, to, err := ParseReminderWindow("22:00-07:00"); err != nil || from != 22*60 || to != 7*60 {
+		t.Errorf("ParseReminderWindow: %d, %d, %v want 1320, 420, nil", from, to, err)
+	}
+	if from, to, err := ParseReminderWindow("all"); err != nil || from != to {
+		t.Errorf("ParseReminderWindow(all): %d, %d, %v want equal minutes, nil", from, to, err)
+	}
+	for _, w := range []string{"08:00-08:00", "08:00", "8-21", "08:00-25:00"} {
+		if _, _, err := ParseReminderWindow(w); err == nil {
+			t.Errorf("ParseReminderWindow(%q): got nil error; want error", w)
+		}
+	}
+	if got, err := ParseWeekdays("mon, Wednesday  Fri"); err != nil || !reflect.DeepEqual(got, []time.Weekday{time.Monday, time.Wednesday, time.Friday}) {
+		t.Errorf("ParseWeekdays: %v, %v want [Monday Wednesday Friday], nil", got, err)
+	}
+	if got, err := ParseWeekdays("all"); err != nil || got != nil {
+		t.Errorf("ParseWeekdays(all): %v, %v want nil, nil", got, err)
+	}
+	if _, err := ParseWeekdays("Mon Funday"); err == nil {
+		t.Errorf("ParseWeekdays(Mon Funday): got nil error; want error")
 	}
 }
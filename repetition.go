@@ -15,6 +15,7 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -24,25 +25,35 @@ import (
 type Repetition struct {
 	db *sql.DB
 	// FIXME: Probably not needed here. Maybe only the number of stages.
-	stages []time.Duration
+	stages     []time.Duration
+	schedulers map[string]Scheduler
+	// Name of the scheduler used when user hasn't chosen one.
+	defaultScheduler string
+}
+
+// Direction in which a word is practiced. Each direction has its own schedule.
+type Direction int
+
+const (
+	// Word is shown, its definition has to be recalled.
+	Forward Direction = iota
+	// Definition is shown with the word masked, the word has to be recalled.
+	Reverse
+)
+
+// prefix returns prefix of the Repetition columns with the schedule of the
+// direction.
+func (d Direction) prefix() string {
+	if d == Reverse {
+		return "reverse_"
+	}
+	return ""
 }
 
 func NewRepetition(dbPath string, stages []time.Duration) (*Repetition, error) {
-	// this is arbitrary big number
-	const maxStages = 1_000_000
 	if len(stages) == 0 {
 		panic("stages == 0")
 	}
-	if len(stages) >= maxStages {
-		panic(fmt.Sprintf("too many stages; should be less than %d", maxStages))
-	}
-	var sv []string
-	for k, s := range stages {
-		sv = append(sv, fmt.Sprintf("(%d, %d)", k, int64(s.Seconds())))
-	}
-	// insert large last id so that words with stages > len(stages) can still
-	// be queried (This can happen if number of stages shrinks)
-	sv = append(sv, fmt.Sprintf("(%d, %d)", maxStages, int64(stages[len(stages)-1].Seconds())))
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, err
@@ -53,20 +64,138 @@ func NewRepetition(dbPath string, stages []time.Duration) (*Repetition, error) {
 			word STRING,
 			definition STRING,
 			stage INTEGER,
-			last_updated_seconds INTEGER -- seconds since UNIX epoch
+			last_updated_seconds INTEGER, -- seconds since UNIX epoch
+			ease REAL NOT NULL DEFAULT 2.5,
+			interval_seconds INTEGER, -- time until the next review
+			lapses INTEGER NOT NULL DEFAULT 0,
+			stability REAL NOT NULL DEFAULT 0,
+			difficulty REAL NOT NULL DEFAULT 0,
+			due_seconds INTEGER, -- seconds since UNIX epoch
+			-- Schedule of practicing the word from its definition.
+			reverse_stage INTEGER NOT NULL DEFAULT 0,
+			reverse_last_updated_seconds INTEGER NOT NULL DEFAULT 0,
+			reverse_ease REAL NOT NULL DEFAULT 2.5,
+			reverse_interval_seconds INTEGER NOT NULL DEFAULT 0,
+			reverse_lapses INTEGER NOT NULL DEFAULT 0,
+			reverse_stability REAL NOT NULL DEFAULT 0,
+			reverse_difficulty REAL NOT NULL DEFAULT 0,
+			reverse_due_seconds INTEGER NOT NULL DEFAULT 0,
+			deck STRING NOT NULL DEFAULT 'Default',
+			suspended INTEGER NOT NULL DEFAULT 0, -- never practiced if not 0
+			buried_until_seconds INTEGER NOT NULL DEFAULT 0 -- seconds since UNIX epoch
+		);
+		-- Decks which were created explicitly, they might be empty.
+		CREATE TABLE IF NOT EXISTS Decks (
+			chat_id INTEGER,
+			name STRING,
+			UNIQUE(chat_id, name)
+		);
+		CREATE TABLE IF NOT EXISTS Tags (
+			chat_id INTEGER,
+			word STRING,
+			tag STRING,
+			UNIQUE(chat_id, word, tag)
 		);
-		CREATE TEMP TABLE IF NOT EXISTS Stages (
-			id INTEGER,
-			duration INTEGER
+		CREATE TABLE IF NOT EXISTS Reviews (
+			chat_id INTEGER,
+			word STRING,
+			grade INTEGER,
+			reviewed_seconds INTEGER, -- seconds since UNIX epoch
+			prev_stage INTEGER,
+			new_stage INTEGER,
+			prev_interval_seconds INTEGER,
+			new_interval_seconds INTEGER,
+			latency_seconds INTEGER, -- NULL if unknown
+			direction INTEGER NOT NULL DEFAULT 0
 		);
-		INSERT INTO Stages(id, duration)
-			VALUES ` +
+		CREATE INDEX IF NOT EXISTS ReviewsByWord ON Reviews(chat_id, word);`,
+	); err != nil {
+		return nil, err
+	}
+	columns, err := tableColumns(db, "Repetition")
+	if err != nil {
+		return nil, err
+	}
+	if err := addMissingColumns(db, "Repetition",
+		"ease REAL NOT NULL DEFAULT 2.5",
+		"interval_seconds INTEGER",
+		"lapses INTEGER NOT NULL DEFAULT 0",
+		"stability REAL NOT NULL DEFAULT 0",
+		"difficulty REAL NOT NULL DEFAULT 0",
+		"due_seconds INTEGER",
+		"reverse_stage INTEGER NOT NULL DEFAULT 0",
+		"reverse_last_updated_seconds INTEGER NOT NULL DEFAULT 0",
+		"reverse_ease REAL NOT NULL DEFAULT 2.5",
+		"reverse_interval_seconds INTEGER NOT NULL DEFAULT 0",
+		"reverse_lapses INTEGER NOT NULL DEFAULT 0",
+		"reverse_stability REAL NOT NULL DEFAULT 0",
+		"reverse_difficulty REAL NOT NULL DEFAULT 0",
+		"reverse_due_seconds INTEGER NOT NULL DEFAULT 0",
+		// Words saved before decks were introduced end up in the default deck.
+		"deck STRING NOT NULL DEFAULT 'Default'",
+		"suspended INTEGER NOT NULL DEFAULT 0",
+		"buried_until_seconds INTEGER NOT NULL DEFAULT 0",
+	); err != nil {
+		return nil, err
+	}
+	if err := addMissingColumns(db, "Reviews",
+		"prev_stage INTEGER",
+		"new_stage INTEGER",
+		"prev_interval_seconds INTEGER",
+		"new_interval_seconds INTEGER",
+		"latency_seconds INTEGER",
+		"direction INTEGER NOT NULL DEFAULT 0",
+	); err != nil {
+		return nil, err
+	}
+	// Rows saved before intervals were introduced get the interval of their
+	// stage. Words with stages >= len(stages) (can happen if number of stages
+	// shrinks) get the last one.
+	var cases []string
+	for k, s := range stages {
+		cases = append(cases, fmt.Sprintf("WHEN %d THEN %d", k, int64(s.Seconds())))
+	}
+	if _, err := db.Exec(`
+		UPDATE Repetition
+		SET interval_seconds = CASE stage ` +
 		// Usually not escaping sql parts can lead to sql injection. In
 		// this case it's more convenient, and only numbers are put inside.
-		strings.Join(sv, ","),
+		strings.Join(cases, " ") +
+		fmt.Sprintf(" ELSE %d END", int64(stages[len(stages)-1].Seconds())) + `
+		WHERE interval_seconds IS NULL;
+		UPDATE Repetition
+		SET due_seconds = last_updated_seconds + interval_seconds
+		WHERE due_seconds IS NULL;`,
 	); err != nil {
 		return nil, err
 	}
+	// Reverse schedule of the words saved before it was introduced follows
+	// the forward one, so that they don't all become due at once.
+	if !columns["reverse_due_seconds"] {
+		if _, err := db.Exec(`
+			UPDATE Repetition
+			SET reverse_last_updated_seconds = last_updated_seconds,
+			    reverse_due_seconds = due_seconds;`,
+		); err != nil {
+			return nil, fmt.Errorf("scheduling reverse practice: %w", err)
+		}
+	}
+	// Words used to be saved more than once. Only the copy with the most
+	// advanced stage is kept, the earliest one if stages are the same.
+	if _, err := db.Exec(`
+		DELETE
+		FROM Repetition
+		WHERE EXISTS (
+			SELECT 1
+			FROM Repetition AS o
+			WHERE o.chat_id = Repetition.chat_id
+			  AND o.word = Repetition.word
+			  AND (o.stage > Repetition.stage
+			    OR (o.stage = Repetition.stage AND o.rowid < Repetition.rowid)));
+		CREATE UNIQUE INDEX IF NOT EXISTS RepetitionByWord ON Repetition(chat_id, word);`,
+	); err != nil {
+		return nil, fmt.Errorf("removing duplicated words: %w", err)
+	}
 	row := db.QueryRow(`
 		SELECT COUNT(*)
 		FROM Repetition;`)
@@ -75,126 +204,490 @@ func NewRepetition(dbPath string, stages []time.Duration) (*Repetition, error) {
 		return nil, err
 	}
 	log.Printf("DEBUG: Repetition database initially contains %d rows!", d)
-	return &Repetition{db, stages}, nil
+	return &Repetition{
+		db:               db,
+		stages:           stages,
+		schedulers:       NewSchedulers(stages),
+		defaultScheduler: LegacyScheduler,
+	}, nil
 }
 
-func (r *Repetition) Save(chatID int64, word, definition string) error {
-	// FIXME: Don't insert duplicates!!!
-	_, err := r.db.Exec(`
-		INSERT INTO Repetition(chat_id, word, definition, stage, last_updated_seconds)
-		VALUES($0, $1, $2, $3, $4)`,
-		chatID, word, definition, 0, time.Now().Unix())
-	return err
+// Scheduler returns the scheduler with the given name and its name. Default
+// scheduler is returned if name is empty or unknown.
+func (r *Repetition) Scheduler(name string) (string, Scheduler) {
+	if s, ok := r.schedulers[name]; ok {
+		return name, s
+	}
+	return r.defaultScheduler, r.schedulers[r.defaultScheduler]
 }
 
-// Repeat retrieves a definitions of the word ready for repetition.
-func (r *Repetition) Repeat(chatID int64) (string, error) {
-	// TODO: Can consider ordering by oldest
-	// TODO: Add a test for this somehow to make sure that correct amount of
-	// time is waited. (can modify last_updated_seconds inside the test to
-	// simulate time)
-	row := r.db.QueryRow(`
-		SELECT word, definition
+// ErrWordExists is returned by Save if the word is already saved.
+var ErrWordExists = errors.New("word is already saved")
+
+// Save saves the word for learning into the deck. Default deck is used if deck
+// is empty. Returns ErrWordExists if the word is already saved, it isn't
+// changed then.
+func (r *Repetition) Save(chatID int64, deck, word, definition string) error {
+	if deck == "" {
+		deck = DefaultDeck
+	}
+	now := time.Now()
+	res, err := r.db.Exec(`
+		INSERT OR IGNORE INTO Repetition(chat_id, word, definition, stage, last_updated_seconds, interval_seconds, due_seconds, deck)
+		VALUES($0, $1, $2, $3, $4, $5, $6, $7)`,
+		chatID, word, definition, 0, now.Unix(), int64(r.stages[0].Seconds()), now.Add(r.stages[0]).Unix(), deck)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: saving %q: %w", word, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("INTERNAL: saving %q: %w", word, err)
+	}
+	if n == 0 {
+		return ErrWordExists
+	}
+	return nil
+}
+
+// Scope limits words which are practiced. Zero value means all words.
+type Scope struct {
+	// Name of the deck, all decks if empty.
+	Deck string
+	// Only words with the tag are practiced if not empty.
+	Tag string
+	// Exclude words never practiced, or the other ones, e.g. when daily
+	// limits are reached.
+	NoNew, NoReviews bool
+	// Order in which words are practiced.
+	Order Order
+}
+
+// where returns condition to be appended to the WHERE clause of the query on
+// Repetition table and its arguments. Condition uses ? placeholders.
+func (s Scope) where() (string, []interface{}) {
+	var (
+		cond string
+		args []interface{}
+	)
+	if s.Deck != "" {
+		cond += " AND deck = ?"
+		args = append(args, s.Deck)
+	}
+	if s.Tag != "" {
+		cond += `
+		  AND EXISTS (
+			SELECT 1
+			FROM Tags
+			WHERE Tags.chat_id = Repetition.chat_id
+			  AND Tags.word = Repetition.word
+			  AND Tags.tag = ?)`
+		args = append(args, s.Tag)
+	}
+	return cond, args
+}
+
+func (s Scope) String() string {
+	r := "all decks"
+	if s.Deck != "" {
+		r = fmt.Sprintf("deck %q", s.Deck)
+	}
+	if s.Tag != "" {
+		r += ", tag #" + s.Tag
+	}
+	return r
+}
+
+// dueQuery returns query for the words in scope ready for repetition in the
+// direction, and its arguments. Suspended words are never ready, buried ones
+// aren't ready until they are unburied.
+func dueQuery(columns string, chatID int64, dir Direction, scope Scope) (string, []interface{}) {
+	cond, args := scope.where()
+	if scope.NoNew {
+		cond += " AND NOT " + newCondition(dir)
+	}
+	if scope.NoReviews {
+		cond += " AND " + newCondition(dir)
+	}
+	// Only constants are put in the query, so there is no risk of sql
+	// injection.
+	q := fmt.Sprintf(`
+		SELECT %s
 		FROM Repetition
-		INNER JOIN Stages ON Repetition.stage <= Stages.id
-		WHERE Repetition.last_updated_seconds + Stages.duration <= $0
-		  AND Repetition.chat_id = $1;`,
-		time.Now().Unix(), chatID)
-	var w, d string
-	err := row.Scan(&w, &d)
+		WHERE %sdue_seconds <= ?
+		  AND chat_id = ?
+		  AND NOT suspended
+		  AND buried_until_seconds <= ?%s;`, columns, dir.prefix(), cond)
+	now := time.Now().Unix()
+	return q, append([]interface{}{now, chatID, now}, args...)
+}
+
+// Repeat retrieves a word in scope ready for repetition in the direction and
+// its definition with the word masked.
+func (r *Repetition) Repeat(chatID int64, dir Direction, scope Scope) (word, question string, err error) {
+	word, err = r.RepeatWord(chatID, dir, scope)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	d, err := r.GetDefinition(chatID, word)
+	if err != nil {
+		return "", "", err
 	}
+	return word, maskWord(word, d), nil
+}
+
+// maskWord strips the word from its definition, so that definition can be
+// used as a question.
+func maskWord(word, definition string) string {
 	// strip first paragraph which corresponds to the word in question.
-	if s := strings.Split(d, "\n\n"); len(s) > 1 {
-		d = strings.Join(s[1:], "\n\n")
+	if s := strings.Split(definition, "\n\n"); len(s) > 1 {
+		definition = strings.Join(s[1:], "\n\n")
 	}
 	// Make sure that the word is not in the question.
-	return strings.ReplaceAll(d, w, "********"), nil
+	return strings.ReplaceAll(definition, word, "********")
 }
 
-// Repeat retrieves a word ready for repetition.
-// TODO: Deduplicate with Repeat?
-func (r *Repetition) RepeatWord(chatID int64) (string, error) {
-	row := r.db.QueryRow(`
-		SELECT word
-		FROM Repetition
-		INNER JOIN Stages ON Repetition.stage <= Stages.id
-		WHERE Repetition.last_updated_seconds + Stages.duration <= $0
-		  AND Repetition.chat_id = $1;`,
-		time.Now().Unix(), chatID)
-	var w string
-	err := row.Scan(&w)
-	return w, err
-}
-
-// looks up definition and compares it to the word
-// FIXME: FIXME: FIXME: FIXME: This doesn't work!!!!!!!!
-//  cannot save obfuscated - cannot check.
-//  cannot save clear - cannot extract raw from obfuscated.
-//  this need fixing - make sure repetition_test passes.
-//  a way to fix is to move obfuscation into commander, save into Asking
-//    not-obfuscated message, but send to user obfuscated one.
-// Maybe this is already fixed, just not tested?
-func (r *Repetition) Answer(chatID int64, definition, word string) (string, error) {
-	panic("This logic is broken, fix it!")
+// RepeatWord retrieves a word in scope ready for repetition in the direction.
+// The word is chosen according to scope.Order. Returns sql.ErrNoRows if there
+// are no such words.
+func (r *Repetition) RepeatWord(chatID int64, dir Direction, scope Scope) (string, error) {
+	ws, err := r.OrderedDueWords(chatID, dir, scope)
+	if err != nil {
+		return "", err
+	}
+	if len(ws) == 0 {
+		return "", sql.ErrNoRows
+	}
+	return ws[0], nil
+}
+
+// OrderedDueWords retrieves all words in scope ready for repetition in the
+// direction in the order of scope.Order.
+func (r *Repetition) OrderedDueWords(chatID int64, dir Direction, scope Scope) ([]string, error) {
+	q, args := dueQuery(fmt.Sprintf("word, %[1]sdue_seconds, %[1]sstage, %[2]s", dir.prefix(), newCondition(dir)), chatID, dir, scope)
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving due words for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var ws []dueWord
+	for rows.Next() {
+		var w dueWord
+		if err := rows.Scan(&w.Word, &w.Due, &w.Stage, &w.New); err != nil {
+			return nil, err
+		}
+		ws = append(ws, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	reviews := 0
+	if scope.Order.Policy == OrderInterleaved {
+		if reviews, err = r.reviewsSinceNew(chatID, dir); err != nil {
+			return nil, err
+		}
+	}
+	var words []string
+	for _, w := range scope.Order.arrange(ws, reviews) {
+		words = append(words, w.Word)
+	}
+	return words, nil
+}
+
+// reviewsSinceNew returns the number of reviews in the direction since the
+// last word was practiced for the first time.
+func (r *Repetition) reviewsSinceNew(chatID int64, dir Direction) (int, error) {
 	row := r.db.QueryRow(`
-		SELECT word, stage
-		FROM Repetition
-		WHERE definition = $0
-		  AND chat_id = $1`,
-		definition, chatID)
-	var correct string
-	var stage int
-	if err := row.Scan(&correct, &stage); err != nil {
-		return "", fmt.Errorf("INTERNAL: Did not find definition %q: %w", definition, err)
+		SELECT COUNT(*)
+		FROM Reviews
+		WHERE chat_id = ?
+		  AND direction = ?
+		  AND reviewed_seconds > (
+			SELECT COALESCE(MAX(first), 0)
+			FROM (
+				SELECT MIN(reviewed_seconds) AS first
+				FROM Reviews
+				WHERE chat_id = ?
+				  AND direction = ?
+				GROUP BY word))`,
+		chatID, dir, chatID, dir)
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, fmt.Errorf("INTERNAL: counting reviews for chat %d: %w", chatID, err)
+	}
+	return n, nil
+}
+
+// DueWords retrieves all words in scope ready for repetition in the direction.
+func (r *Repetition) DueWords(chatID int64, dir Direction, scope Scope) ([]string, error) {
+	q, args := dueQuery("word", chatID, dir, scope)
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving due words for chat %d: %w", chatID, err)
 	}
-	if correct != word {
-		stage = 0
-	} else {
-		stage += 1
-		if stage >= len(r.stages) {
-			stage = len(r.stages) - 1
+	defer rows.Close()
+	var ws []string
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
 		}
+		ws = append(ws, w)
 	}
-	_, err := r.db.Exec(`
-		UPDATE Repetition
-		SET stage = $0, last_updated_seconds = $1
-		WHERE definition = $2
-		  AND chat_id = $3;`,
-		stage, time.Now().Unix(), definition, chatID)
+	return ws, rows.Err()
+}
+
+// AnswerGrade reschedules the word answered in the direction with the grade
+// using the scheduler and records the answer in the word's history. shown is
+// the time when the card was shown to the user, zero if unknown. Returns the
+// new schedule of the word.
+func (r *Repetition) AnswerGrade(chatID int64, word string, dir Direction, g Grade, sched Scheduler, shown time.Time) (Card, error) {
+	// Only constants are put in the queries, so there is no risk of sql
+	// injection.
+	row := r.db.QueryRow(fmt.Sprintf(`
+		SELECT %[1]sstage, %[1]sinterval_seconds, %[1]slapses, %[1]slast_updated_seconds,
+			%[1]sease, %[1]sstability, %[1]sdifficulty
+		FROM Repetition
+		WHERE word = $0
+		  AND chat_id = $1`, dir.prefix()),
+		word, chatID)
+	var (
+		c                  Card
+		interval, lastSecs int64
+	)
+	if err := row.Scan(&c.Stage, &interval, &c.Lapses, &lastSecs, &c.Ease, &c.Stability, &c.Difficulty); err != nil {
+		return Card{}, fmt.Errorf("INTERNAL: Did not find %q: %w", word, err)
+	}
+	c.Interval = time.Duration(interval) * time.Second
+	c.LastReview = time.Unix(lastSecs, 0)
+	history, err := r.History(chatID, word, dir)
 	if err != nil {
-		return "", fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
+		return Card{}, err
 	}
-	return correct, nil
+
+	now := time.Now()
+	prev := c
+	c = sched.Schedule(c, history, g, now)
+	if _, err := r.db.Exec(fmt.Sprintf(`
+		UPDATE Repetition
+		SET %[1]sstage = $0, %[1]sinterval_seconds = $1, %[1]slapses = $2, %[1]sease = $3,
+			%[1]sstability = $4, %[1]sdifficulty = $5, %[1]slast_updated_seconds = $6, %[1]sdue_seconds = $7
+		WHERE word = $8
+		  AND chat_id = $9;`, dir.prefix()),
+		c.Stage, int64(c.Interval.Seconds()), c.Lapses, c.Ease,
+		c.Stability, c.Difficulty, now.Unix(), now.Add(c.Interval).Unix(),
+		word, chatID); err != nil {
+		return Card{}, fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
+	}
+	var latency sql.NullInt64
+	if !shown.IsZero() {
+		latency = sql.NullInt64{Int64: int64(now.Sub(shown).Seconds()), Valid: true}
+	}
+	if _, err := r.db.Exec(`
+		INSERT INTO Reviews(chat_id, word, grade, reviewed_seconds,
+			prev_stage, new_stage, prev_interval_seconds, new_interval_seconds, latency_seconds, direction)
+		VALUES($0, $1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		chatID, word, g, now.Unix(),
+		prev.Stage, c.Stage, int64(prev.Interval.Seconds()), int64(c.Interval.Seconds()), latency, dir); err != nil {
+		return Card{}, fmt.Errorf("INTERNAL: Failed saving review: %w", err)
+	}
+	return c, nil
 }
 
-func (r *Repetition) AnswerKnow(chatID int64, word string) error {
-	_, err := r.db.Exec(`
+// SetSchedule overwrites schedule of the word in the direction, e.g. when it's
+// imported.
+func (r *Repetition) SetSchedule(chatID int64, word string, dir Direction, c Card, due time.Time) error {
+	if _, err := r.db.Exec(fmt.Sprintf(`
 		UPDATE Repetition
-		SET stage = MIN(stage + 1, $0), last_updated_seconds = $1
-		WHERE word = $2
-		  AND chat_id = $3;`,
-		len(r.stages)-1, time.Now().Unix(), word, chatID)
-	if err != nil {
-		return fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
+		SET %[1]sstage = $0, %[1]sinterval_seconds = $1, %[1]slapses = $2, %[1]sease = $3,
+			%[1]sstability = $4, %[1]sdifficulty = $5, %[1]slast_updated_seconds = $6, %[1]sdue_seconds = $7
+		WHERE word = $8
+		  AND chat_id = $9;`, dir.prefix()),
+		c.Stage, int64(c.Interval.Seconds()), c.Lapses, c.Ease,
+		c.Stability, c.Difficulty, c.LastReview.Unix(), due.Unix(),
+		word, chatID); err != nil {
+		return fmt.Errorf("INTERNAL: Failed setting schedule of %q: %w", word, err)
 	}
 	return nil
 }
 
-func (r *Repetition) AnswerDontKnow(chatID int64, word string) error {
-	_, err := r.db.Exec(`
+// ResetProgress schedules the word in the direction like a newly saved one.
+// Lapses are kept and nothing is added to the history, as the word wasn't
+// answered.
+func (r *Repetition) ResetProgress(chatID int64, word string, dir Direction) error {
+	now := time.Now()
+	res, err := r.db.Exec(fmt.Sprintf(`
 		UPDATE Repetition
-		SET stage = 0, last_updated_seconds = $0
-		WHERE word = $1
-		  AND chat_id = $2;`,
-		time.Now().Unix(), word, chatID)
+		SET %[1]sstage = 0, %[1]sinterval_seconds = $0, %[1]sease = 2.5, %[1]sstability = 0,
+			%[1]sdifficulty = 0, %[1]slast_updated_seconds = $1, %[1]sdue_seconds = $2
+		WHERE word = $3
+		  AND chat_id = $4;`, dir.prefix()),
+		int64(r.stages[0].Seconds()), now.Unix(), now.Add(r.stages[0]).Unix(),
+		word, chatID)
+	if err != nil {
+		return fmt.Errorf("INTERNAL: Failed resetting progress of %q: %w", word, err)
+	}
+	n, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("INTERNAL: Failed updating stage: %w", err)
+		return fmt.Errorf("INTERNAL: Failed resetting progress of %q: %w", word, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("INTERNAL: Did not find %q: %w", word, sql.ErrNoRows)
 	}
 	return nil
 }
 
+// StageFor returns the stage which is the closest to the interval without
+// exceeding it.
+func (r *Repetition) StageFor(interval time.Duration) int {
+	stage := 0
+	for i, s := range r.stages {
+		if s <= interval {
+			stage = i
+		}
+	}
+	return stage
+}
+
+// Records returns all words of the chat with their schedules and history in
+// the order they were saved.
+func (r *Repetition) Records(chatID int64) ([]*CardRecord, error) {
+	rows, err := r.db.Query(`
+		SELECT word, definition, deck,
+			stage, interval_seconds, lapses, last_updated_seconds,
+			ease, stability, difficulty, due_seconds,
+			reverse_stage, reverse_interval_seconds, reverse_lapses, reverse_last_updated_seconds,
+			reverse_ease, reverse_stability, reverse_difficulty, reverse_due_seconds,
+			suspended, buried_until_seconds
+		FROM Repetition
+		WHERE chat_id = $0
+		ORDER BY rowid`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving words of chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var rs []*CardRecord
+	for rows.Next() {
+		var (
+			rc                       CardRecord
+			c, rev                   Card
+			interval, lastSecs, secs int64
+			revInterval, revLastSecs int64
+			revSecs, buriedSecs      int64
+		)
+		if err := rows.Scan(&rc.Front, &rc.Back, &rc.Deck,
+			&c.Stage, &interval, &c.Lapses, &lastSecs,
+			&c.Ease, &c.Stability, &c.Difficulty, &secs,
+			&rev.Stage, &revInterval, &rev.Lapses, &revLastSecs,
+			&rev.Ease, &rev.Stability, &rev.Difficulty, &revSecs,
+			&rc.Suspended, &buriedSecs); err != nil {
+			return nil, err
+		}
+		if buriedSecs != 0 {
+			rc.BuriedUntil = time.Unix(buriedSecs, 0)
+		}
+		c.Interval = time.Duration(interval) * time.Second
+		c.LastReview = time.Unix(lastSecs, 0)
+		rc.Schedule = &c
+		rc.Due = time.Unix(secs, 0)
+		rev.Interval = time.Duration(revInterval) * time.Second
+		rev.LastReview = time.Unix(revLastSecs, 0)
+		rc.ReverseSchedule = &rev
+		rc.ReverseDue = time.Unix(revSecs, 0)
+		rs = append(rs, &rc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, rc := range rs {
+		if rc.Tags, err = r.Tags(chatID, rc.Front); err != nil {
+			return nil, err
+		}
+		if rc.Reviews, err = r.History(chatID, rc.Front, Forward); err != nil {
+			return nil, err
+		}
+		if rc.ReverseReviews, err = r.History(chatID, rc.Front, Reverse); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+// AddReviews adds reviews of the word in the direction to its history, e.g.
+// when it's imported.
+func (r *Repetition) AddReviews(chatID int64, word string, dir Direction, rs []Review) error {
+	for _, rv := range rs {
+		var latency sql.NullInt64
+		if rv.Latency >= 0 {
+			latency = sql.NullInt64{Int64: int64(rv.Latency.Seconds()), Valid: true}
+		}
+		if _, err := r.db.Exec(`
+			INSERT INTO Reviews(chat_id, word, grade, reviewed_seconds,
+				prev_stage, new_stage, prev_interval_seconds, new_interval_seconds, latency_seconds, direction)
+			VALUES($0, $1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			chatID, word, rv.Grade, rv.Time.Unix(),
+			rv.PrevStage, rv.NewStage, int64(rv.PrevInterval.Seconds()), int64(rv.NewInterval.Seconds()), latency, dir); err != nil {
+			return fmt.Errorf("INTERNAL: Failed saving review: %w", err)
+		}
+	}
+	return nil
+}
+
+// History returns all reviews of the word in the direction, oldest first.
+func (r *Repetition) History(chatID int64, word string, dir Direction) ([]Review, error) {
+	rows, err := r.db.Query(`
+		SELECT grade, reviewed_seconds, prev_stage, new_stage,
+			prev_interval_seconds, new_interval_seconds, latency_seconds
+		FROM Reviews
+		WHERE chat_id = $0
+		  AND word = $1
+		  AND direction = $2
+		ORDER BY reviewed_seconds, rowid`,
+		chatID, word, dir)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving history of %q: %w", word, err)
+	}
+	defer rows.Close()
+	var h []Review
+	for rows.Next() {
+		var (
+			rv                     Review
+			secs                   int64
+			prevStage, newStage    sql.NullInt64
+			prevInterval, interval sql.NullInt64
+			latency                sql.NullInt64
+		)
+		if err := rows.Scan(&rv.Grade, &secs, &prevStage, &newStage, &prevInterval, &interval, &latency); err != nil {
+			return nil, err
+		}
+		rv.Time = time.Unix(secs, 0)
+		rv.PrevStage = int(prevStage.Int64)
+		rv.NewStage = int(newStage.Int64)
+		rv.PrevInterval = time.Duration(prevInterval.Int64) * time.Second
+		rv.NewInterval = time.Duration(interval.Int64) * time.Second
+		rv.Latency = -1
+		if latency.Valid {
+			rv.Latency = time.Duration(latency.Int64) * time.Second
+		}
+		h = append(h, rv)
+	}
+	return h, rows.Err()
+}
+
+// AnswerKnow reschedules the word using the default scheduler.
+func (r *Repetition) AnswerKnow(chatID int64, word string) error {
+	_, s := r.Scheduler("")
+	_, err := r.AnswerGrade(chatID, word, Forward, GradeGood, s, time.Time{})
+	return err
+}
+
+// AnswerDontKnow reschedules the word using the default scheduler.
+func (r *Repetition) AnswerDontKnow(chatID int64, word string) error {
+	_, s := r.Scheduler("")
+	_, err := r.AnswerGrade(chatID, word, Forward, GradeAgain, s, time.Time{})
+	return err
+}
+
 func (r *Repetition) GetDefinition(chatID int64, word string) (string, error) {
 	row := r.db.QueryRow(`
 		SELECT definition
@@ -209,6 +702,48 @@ func (r *Repetition) GetDefinition(chatID int64, word string) (string, error) {
 	return d, nil
 }
 
+// Definitions returns all saved words of the chat with their definitions.
+func (r *Repetition) Definitions(chatID int64) (map[string]string, error) {
+	rows, err := r.db.Query(`
+		SELECT word, definition
+		FROM Repetition
+		WHERE chat_id = $0`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: Retrieving definitions for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	ds := make(map[string]string)
+	for rows.Next() {
+		var w, d string
+		if err := rows.Scan(&w, &d); err != nil {
+			return nil, fmt.Errorf("INTERNAL: Retrieving definitions for chat %d: %w", chatID, err)
+		}
+		ds[w] = d
+	}
+	return ds, rows.Err()
+}
+
+// Chats returns all chats which have saved words.
+func (r *Repetition) Chats() ([]int64, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT chat_id
+		FROM Repetition`)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving chats: %w", err)
+	}
+	defer rows.Close()
+	var cs []int64
+	for rows.Next() {
+		var c int64
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+	}
+	return cs, rows.Err()
+}
+
 func (r *Repetition) Exists(chatID int64, word string) (bool, error) {
 	row := r.db.QueryRow(`
 			SELECT COUNT(*) FROM Repetition
@@ -223,18 +758,44 @@ func (r *Repetition) Exists(chatID int64, word string) (bool, error) {
 }
 
 func (r *Repetition) Delete(chatID int64, word string) error {
-	_, err := r.db.Exec(`
-		DELETE
-		FROM Repetition
-		WHERE word = $0
-		  AND chat_id = $1`,
-		word, chatID)
-	if err != nil {
-		return fmt.Errorf("Failed deleting %q: %w", word, err)
+	for _, table := range []string{"Repetition", "Reviews", "Tags"} {
+		_, err := r.db.Exec(`
+			DELETE
+			FROM `+table+`
+			WHERE word = $0
+			  AND chat_id = $1`,
+			word, chatID)
+		if err != nil {
+			return fmt.Errorf("Failed deleting %q: %w", word, err)
+		}
 	}
 	return nil
 }
 
-// TODO later editing should be helpful.
-// func (r *Repetition) Edit(chatID int64, word, newDefinition string) {
-// }
+// Edit changes the word and its definition. Progress, reviews and tags are
+// kept. newWord must not be saved already unless it's the same word.
+func (r *Repetition) Edit(chatID int64, word, newWord, newDefinition string) error {
+	if _, err := r.db.Exec(`
+		UPDATE Repetition
+		SET word = $0,
+			definition = $1
+		WHERE word = $2
+		  AND chat_id = $3`,
+		newWord, newDefinition, word, chatID); err != nil {
+		return fmt.Errorf("INTERNAL: editing %q: %w", word, err)
+	}
+	if newWord == word {
+		return nil
+	}
+	for _, table := range []string{"Reviews", "Tags"} {
+		if _, err := r.db.Exec(`
+			UPDATE `+table+`
+			SET word = $0
+			WHERE word = $1
+			  AND chat_id = $2`,
+			newWord, word, chatID); err != nil {
+			return fmt.Errorf("INTERNAL: renaming %q to %q: %w", word, newWord, err)
+		}
+	}
+	return nil
+}
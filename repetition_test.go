@@ -14,9 +14,11 @@
 package main
 
 import (
+	"database/sql"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -65,45 +67,20 @@ func TestRepetition(t *testing.T) {
 	}
 
 	const chatId int64 = 1
-	if err := r.Save(chatId, "foo", "foo is bar"); err != nil {
+	if err := r.Save(chatId, "", "foo", "foo is bar"); err != nil {
 		t.Fatal(err)
 	}
 	check(&row{chatId: chatId, word: "foo", definition: "foo is bar", stage: 0})
 
-	d, err := r.Repeat(chatId)
+	w, d, err := r.Repeat(chatId, Forward, Scope{})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if d != "******** is bar" {
-		t.Errorf("got %q; want %q", d, "foo is bar")
+	if w != "foo" || d != "******** is bar" {
+		t.Errorf("got %q, %q; want %q, %q", w, d, "foo", "******** is bar")
 	}
 	check(&row{chatId: chatId, word: "foo", definition: "foo is bar", stage: 0})
 
-	t.Run("Answer", func(t *testing.T) {
-		// Answer is broken
-		t.Skip()
-		if _, err := r.Answer(chatId, d, "foo"); err != nil {
-			t.Fatal(err)
-		}
-		check(&row{chatId: chatId, word: "foo", definition: "foo is bar", stage: 1})
-
-		corrected, err := r.Answer(chatId, d, "wrong")
-		if err != nil {
-			t.Fatal(err)
-		}
-		if corrected != "foo" {
-			t.Errorf("got %q; want foo", corrected)
-		}
-		check(&row{chatId: chatId, word: "foo", definition: "foo is bar", stage: 0})
-
-		for _, want := range []int32{1, 2, 3, 3, 3} {
-			if _, err := r.Answer(chatId, d, "foo"); err != nil {
-				t.Fatal(err)
-			}
-			check(&row{chatId: chatId, word: "foo", definition: "foo is bar", stage: want})
-		}
-	})
-
 	// Test simpler know - don't know
 	if err := r.AnswerDontKnow(chatId, "foo"); err != nil {
 		t.Fatal(err)
@@ -134,3 +111,279 @@ func TestRepetition(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRepetitionScheduler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	db := filepath.Join(dir, "tmpdb")
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sm2 := r.Scheduler(SM2Scheduler)
+
+	const chatID int64 = 1
+	if err := r.Save(chatID, "", "foo", "foo is bar"); err != nil {
+		t.Fatal(err)
+	}
+	if w, err := r.RepeatWord(chatID, Forward, Scope{}); err != nil || w != "foo" {
+		t.Fatalf("RepeatWord: %q, %v want foo, nil", w, err)
+	}
+
+	shown := time.Now().Add(-5 * time.Second)
+	if _, err := r.AnswerGrade(chatID, "foo", Forward, GradeGood, sm2, shown); err != nil {
+		t.Fatal(err)
+	}
+	row := r.db.QueryRow(`
+		SELECT stage, interval_seconds
+		FROM Repetition
+		WHERE chat_id = $0 AND word = $1`,
+		chatID, "foo")
+	var stage, interval int64
+	if err := row.Scan(&stage, &interval); err != nil {
+		t.Fatal(err)
+	}
+	if stage != 1 || interval != int64(day.Seconds()) {
+		t.Errorf("got stage %d, interval %d; want 1, %d", stage, interval, int64(day.Seconds()))
+	}
+	if w, err := r.RepeatWord(chatID, Forward, Scope{}); err != sql.ErrNoRows {
+		t.Errorf("RepeatWord: %q, %v want sql.ErrNoRows", w, err)
+	}
+
+	// Reverse direction has its own schedule.
+	if w, q, err := r.Repeat(chatID, Reverse, Scope{}); err != nil || w != "foo" || q != "******** is bar" {
+		t.Fatalf("Repeat(Reverse): %q, %q, %v want foo, ******** is bar, nil", w, q, err)
+	}
+	if _, err := r.AnswerGrade(chatID, "foo", Reverse, GradeAgain, sm2, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := r.History(chatID, "foo", Reverse); err != nil || len(h) != 1 || h[0].Grade != GradeAgain {
+		t.Errorf("History(Reverse): %+v, %v want single Again review", h, err)
+	}
+
+	// Simulate a day passing.
+	if _, err := r.db.Exec(`
+		UPDATE Repetition
+		SET last_updated_seconds = last_updated_seconds - $0,
+			due_seconds = due_seconds - $1`,
+		int64(day.Seconds()), int64(day.Seconds())); err != nil {
+		t.Fatal(err)
+	}
+	if w, err := r.RepeatWord(chatID, Forward, Scope{}); err != nil || w != "foo" {
+		t.Errorf("RepeatWord: %q, %v want foo, nil", w, err)
+	}
+
+	// Switching scheduler keeps the history.
+	_, fsrs := r.Scheduler(FSRSScheduler)
+	if _, err := r.AnswerGrade(chatID, "foo", Forward, GradeAgain, fsrs, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := r.History(chatID, "foo", Forward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range h {
+		h[i].Time = time.Time{}
+		// Allow for the test being slow.
+		if h[i].Latency >= 5*time.Second && h[i].Latency < 10*time.Second {
+			h[i].Latency = 5 * time.Second
+		}
+	}
+	want := []Review{{
+		Grade:        GradeGood,
+		PrevStage:    0,
+		NewStage:     1,
+		PrevInterval: 0,
+		NewInterval:  day,
+		Latency:      5 * time.Second,
+	}, {
+		Grade:        GradeAgain,
+		PrevStage:    1,
+		NewStage:     0,
+		PrevInterval: day,
+		NewInterval:  0,
+		Latency:      -1,
+	}}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got history %+v; want %+v", h, want)
+	}
+
+	// Renamed word keeps its history.
+	if err := r.Edit(chatID, "foo", "bar", "trimmed"); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := r.GetDefinition(chatID, "bar"); err != nil || d != "trimmed" {
+		t.Errorf("GetDefinition(bar) after edit: %q, %v want trimmed, nil", d, err)
+	}
+	if e, err := r.Exists(chatID, "foo"); err != nil || e {
+		t.Errorf("Exists(foo) after edit: %t, %v want false, nil", e, err)
+	}
+	if h, err := r.History(chatID, "bar", Forward); err != nil || len(h) != len(want) {
+		t.Errorf("History(bar) after edit: %v, %v want %d reviews", h, err, len(want))
+	}
+
+	if err := r.Delete(chatID, "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := r.History(chatID, "bar", Forward); err != nil || len(h) != 0 {
+		t.Errorf("History after delete: %v, %v want empty", h, err)
+	}
+}
+
+func TestRepetitionDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "tmpdb")
+	// Database created before words were unique.
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE Repetition (
+			chat_id INTEGER,
+			word STRING,
+			definition STRING,
+			stage INTEGER,
+			last_updated_seconds INTEGER
+		);
+		INSERT INTO Repetition VALUES(1, "foo", "first", 1, 0);
+		INSERT INTO Repetition VALUES(1, "foo", "advanced", 2, 0);
+		INSERT INTO Repetition VALUES(1, "foo", "second advanced", 2, 0);
+		INSERT INTO Repetition VALUES(2, "foo", "other chat", 0, 0);`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	r, err := NewRepetition(dbPath, []time.Duration{0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, tc := range []struct {
+		chatID int64
+		want   string
+	}{{chatID, "advanced"}, {2, "other chat"}} {
+		if got, err := r.GetDefinition(tc.chatID, "foo"); err != nil || got != tc.want {
+			t.Errorf("GetDefinition(%d, foo): %q, %v want %q, nil", tc.chatID, got, err, tc.want)
+		}
+	}
+	var n int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM Repetition").Scan(&n); err != nil || n != 2 {
+		t.Errorf("got %d rows, %v; want 2", n, err)
+	}
+
+	if err := r.Save(chatID, "", "foo", "new"); err != ErrWordExists {
+		t.Errorf("Save(foo): %v want ErrWordExists", err)
+	}
+	if got, err := r.GetDefinition(chatID, "foo"); err != nil || got != "advanced" {
+		t.Errorf("GetDefinition(foo) after Save: %q, %v want advanced, nil", got, err)
+	}
+	if err := r.Save(chatID, "", "bar", "new"); err != nil {
+		t.Errorf("Save(bar): %v want nil", err)
+	}
+}
+
+func TestMergeDeletedWord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	if err := c.Repetitions.Save(chatID, "", "foo", "old"); err != nil {
+		t.Fatal(err)
+	}
+	// The word is deleted before the user picks what to do with the new
+	// definition.
+	if err := c.Repetitions.Delete(chatID, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	q := &CallbackQuery{
+		Id:      "0",
+		Message: &Message{Text: "new"},
+		Data:    MergeCallback{"foo", ReplaceDefinitionAction}.AsInlineKeyboard().CallbackData,
+	}
+	q.Message.Chat.Id = chatID
+	if err := c.Update(&Update{CallbackQuery: q}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Word "foo" isn't saved for learning!`
+	if len(fk.messages) == 0 || fk.messages[len(fk.messages)-1].Text != want {
+		t.Errorf("got messages %v; want the last one %q", fk.messages, want)
+	}
+	if e, err := c.Repetitions.Exists(chatID, "foo"); err != nil || e {
+		t.Errorf("Exists(foo) after merge: %t, %v want false, nil", e, err)
+	}
+}
+
+func TestReverseScheduleMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	dbPath := filepath.Join(dir, "tmpdb")
+	// Database created before reverse practice.
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE Repetition (
+			chat_id INTEGER,
+			word STRING,
+			definition STRING,
+			stage INTEGER,
+			last_updated_seconds INTEGER
+		);
+		INSERT INTO Repetition VALUES(1, "foo", "learned", 1, ?);
+		INSERT INTO Repetition VALUES(1, "bar", "new", 0, 0);`,
+		time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	r, err := NewRepetition(dbPath, []time.Duration{0, 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	// Reverse practice follows the forward schedule.
+	for _, dir := range []Direction{Forward, Reverse} {
+		if got, err := r.DueWords(chatID, dir, Scope{}); err != nil || !reflect.DeepEqual(got, []string{"bar"}) {
+			t.Errorf("DueWords(%d): %q, %v want [bar], nil", dir, got, err)
+		}
+	}
+
+	// Schedules are independent once the column exists.
+	_, legacy := r.Scheduler(LegacyScheduler)
+	if _, err := r.AnswerGrade(chatID, "bar", Reverse, GradeGood, legacy, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewRepetition(dbPath, []time.Duration{0, 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.DueWords(chatID, Reverse, Scope{}); err != nil || len(got) != 0 {
+		t.Errorf("DueWords(Reverse) after answer: %q, %v want none", got, err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Schedulers decide when the card should be shown next.
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Grade is how well the user remembered the card.
+type Grade int
+
+const (
+	GradeAgain Grade = iota
+	GradeHard
+	GradeGood
+	GradeEasy
+)
+
+var Grades = []Grade{GradeAgain, GradeHard, GradeGood, GradeEasy}
+
+func (g Grade) String() string {
+	switch g {
+	case GradeAgain:
+		return "Again"
+	case GradeHard:
+		return "Hard"
+	case GradeGood:
+		return "Good"
+	case GradeEasy:
+		return "Easy"
+	}
+	return fmt.Sprintf("Grade(%d)", int(g))
+}
+
+// Card is the scheduling state of a single card. Schedulers use only the
+// fields they need.
+type Card struct {
+	// Number of successful reviews in a row.
+	Stage int
+	// Time between the last review and the next one.
+	Interval time.Duration
+	// Number of times the card was forgotten, including the ones before it
+	// was learned, so that words which are never learned become leeches.
+	Lapses     int
+	LastReview time.Time
+	// SM-2 ease factor.
+	Ease float64
+	// FSRS memory state.
+	Stability  float64
+	Difficulty float64
+}
+
+// Review is a single answer to the card.
+type Review struct {
+	Time  time.Time
+	Grade Grade
+	// Schedule of the card before and after the answer.
+	PrevStage    int
+	NewStage     int
+	PrevInterval time.Duration
+	NewInterval  time.Duration
+	// Time it took the user to answer since the card was shown. Negative if
+	// unknown.
+	Latency time.Duration
+}
+
+type Scheduler interface {
+	// Schedule returns the state of the card after it was answered with the
+	// grade at the time now. history contains all previous reviews of the
+	// card, oldest first. The card is next due at now + Interval.
+	Schedule(c Card, history []Review, g Grade, now time.Time) Card
+}
+
+const (
+	// LegacyScheduler moves cards through the fixed stages.
+	LegacyScheduler = "legacy"
+	// SM2Scheduler adapts intervals to each card using SuperMemo 2.
+	SM2Scheduler = "sm2"
+	// FSRSScheduler models memory stability and difficulty of each card.
+	FSRSScheduler = "fsrs"
+)
+
+// NewSchedulers returns all supported schedulers by their names.
+// stages are used by legacy scheduler, and the first stage is the time after
+// which forgotten cards are shown again by the adaptive schedulers.
+func NewSchedulers(stages []time.Duration) map[string]Scheduler {
+	return map[string]Scheduler{
+		LegacyScheduler: legacyScheduler{stages},
+		SM2Scheduler:    sm2Scheduler{relearn: stages[0]},
+		FSRSScheduler:   fsrsScheduler{relearn: stages[0], retention: fsrsRetention, w: fsrsWeights},
+	}
+}
+
+var SchedulerNames = []string{FSRSScheduler, LegacyScheduler, SM2Scheduler}
+
+func ValidateScheduler(s string) error {
+	for _, n := range SchedulerNames {
+		if n == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported scheduler %q. Supported are %s", s, strings.Join(SchedulerNames, ", "))
+}
+
+type legacyScheduler struct {
+	stages []time.Duration
+}
+
+func (s legacyScheduler) Schedule(c Card, _ []Review, g Grade, _ time.Time) Card {
+	if g == GradeAgain {
+		c.Lapses++
+		c.Stage = 0
+	} else if c.Stage++; c.Stage >= len(s.stages) {
+		c.Stage = len(s.stages) - 1
+	}
+	c.Interval = s.stages[c.Stage]
+	return c
+}
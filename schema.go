@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// addMissingColumns adds columns to the existing table if they are not there
+// yet. It's used to migrate databases created before the columns were
+// introduced. Each column is a definition as in CREATE TABLE, e.g.
+// "lapses INTEGER NOT NULL DEFAULT 0".
+func addMissingColumns(db *sql.DB, table string, columns ...string) error {
+	existing, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		name := strings.Fields(c)[0]
+		if existing[name] {
+			continue
+		}
+		// Only constants are passed here, so there is no risk of sql injection.
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, c)); err != nil {
+			return fmt.Errorf("adding column %q to %s: %w", name, table, err)
+		}
+	}
+	return nil
+}
+
+// tableColumns returns names of the table's columns.
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull bool
+			def     sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &def, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	return existing, rows.Err()
+}
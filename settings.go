@@ -17,6 +17,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Settings struct {
@@ -31,27 +33,118 @@ type Settings struct {
 	// true if translation is accepted
 	TranslationLanguages map[string]bool
 	TimeZone             string
+	// Scheduler used for practice. Default one is used if empty.
+	Scheduler string
+	// If true, spelling practice accepts answers without diacritics (a for á).
+	IgnoreDiacritics bool
+	// Deck into which words are saved. Default deck is used if empty.
+	Deck string
+	// Deck which is practiced. All decks are practiced if empty.
+	PracticeDeck string
+	// If not empty only words with the tag are practiced.
+	PracticeTag string
+	// Maximum number of new words and reviews per day, 0 if unlimited.
+	NewCardsPerDay int
+	ReviewsPerDay  int
+	// Order of practicing due words, see ParseOrder. Most overdue words are
+	// practiced first if empty.
+	Order string
+	// Reminders are sent between these minutes since midnight in TimeZone,
+	// during the whole day if they are equal. Window crosses midnight if it
+	// ends before it starts.
+	RemindFromMinutes int
+	RemindToMinutes   int
+	// Reminders per day spread evenly across the window, 1 if not set.
+	RemindersPerDay int
+	// Days of the week when reminders are sent, every day if empty.
+	RemindWeekdays []time.Weekday
+}
+
+// PracticeOrder returns the order of practicing due words.
+func (s *Settings) PracticeOrder() Order {
+	o, err := ParseOrder(s.Order)
+	if err != nil {
+		return Order{Policy: OrderOverdue}
+	}
+	return o
+}
+
+// DailyLimits returns the maximum number of words practiced per day.
+func (s *Settings) DailyLimits() DailyLimits {
+	return DailyLimits{NewCards: s.NewCardsPerDay, Reviews: s.ReviewsPerDay}
+}
+
+// PracticeScope returns words which the user has chosen to practice.
+func (s *Settings) PracticeScope() Scope {
+	return Scope{Deck: s.PracticeDeck, Tag: s.PracticeTag}
+}
+
+// ActiveDeck returns the deck into which words are saved.
+func (s *Settings) ActiveDeck() string {
+	if s.Deck == "" {
+		return DefaultDeck
+	}
+	return s.Deck
 }
 
 func SettingsFromString(s string) *Settings {
-	var m Settings
+	// Settings saved before reminder windows were introduced don't have them
+	// and get the default window rather than the whole day.
+	m := Settings{
+		RemindFromMinutes: defaultRemindFromMinutes,
+		RemindToMinutes:   defaultRemindToMinutes,
+	}
 	if err := json.Unmarshal([]byte(s), &m); err != nil {
 		panic(err)
 	}
 	return &m
 }
 
+// DefaultSettings returns settings of new users with the default built-in
+// language.
 func DefaultSettings() *Settings {
+	return newSettings(defaultLanguages.Default())
+}
+
+// Translations returns languages into which usage examples are translated,
+// except for the input language itself.
+func (s *Settings) Translations() map[string]bool {
+	ts := make(map[string]bool)
+	for l, v := range s.TranslationLanguages {
+		if l != s.InputLanguageISO639_3 {
+			ts[l] = v
+		}
+	}
+	return ts
+}
+
+// newSettings returns settings of a new user with the input language.
+func newSettings(lang *Language) *Settings {
+	translations := make(map[string]bool)
+	for _, l := range lang.TranslationLanguages {
+		translations[l] = true
+	}
 	return &Settings{
-		InputLanguage:         "Hungarian",
-		InputLanguageISO639_3: "hun",
-		TranslationLanguages: map[string]bool{
-			"eng": true,
-			"rus": true,
-			"ukr": true,
-		},
-		TimeZone: "UTC",
+		InputLanguage:         lang.Name,
+		InputLanguageISO639_3: lang.ISO639_3,
+		TranslationLanguages:  translations,
+		TimeZone:              "UTC",
+		NewCardsPerDay:        defaultNewCardsPerDay,
+		ReviewsPerDay:         defaultReviewsPerDay,
+		RemindFromMinutes:     defaultRemindFromMinutes,
+		RemindToMinutes:       defaultRemindToMinutes,
+		RemindersPerDay:       1,
+	}
+}
+
+// Location returns the time zone of the user. UTC is returned if time zone
+// isn't set or can't be parsed.
+func (s *Settings) Location() *time.Location {
+	_, loc, err := ParseTimeZone(s.TimeZone)
+	if err != nil {
+		return time.UTC
 	}
+	return loc
 }
 
 func (s Settings) String() string {
@@ -64,6 +157,8 @@ func (s Settings) String() string {
 
 type SettingsConfig struct {
 	db *sql.DB
+	// Languages which can be chosen as input language.
+	Languages *Languages
 }
 
 func NewSettingsConfig(dbPath string) (*SettingsConfig, error) {
@@ -78,7 +173,55 @@ func NewSettingsConfig(dbPath string) (*SettingsConfig, error) {
 		);`); err != nil {
 		return nil, err
 	}
-	return &SettingsConfig{db}, nil
+	if err := migrateTimeZones(db); err != nil {
+		return nil, fmt.Errorf("INTERNAL: migrating time zones: %w", err)
+	}
+	return &SettingsConfig{db: db, Languages: defaultLanguages}, nil
+}
+
+// migrateTimeZones rewrites legacy UTC offsets into the canonical form
+// returned by ParseTimeZone, e.g. "UTC+0" becomes "UTC". Offsets which can't
+// be parsed are replaced with UTC, which is what they were treated as before.
+// Other time zones are left alone, even if they can't be loaded.
+func migrateTimeZones(db *sql.DB) error {
+	rows, err := db.Query(`SELECT chat_id, settings FROM Settings`)
+	if err != nil {
+		return err
+	}
+	migrated := make(map[int64]*Settings)
+	for rows.Next() {
+		var (
+			chatID int64
+			s      string
+		)
+		if err := rows.Scan(&chatID, &s); err != nil {
+			rows.Close()
+			return err
+		}
+		settings := SettingsFromString(s)
+		if settings.TimeZone != "" && !strings.HasPrefix(settings.TimeZone, "UTC") {
+			continue
+		}
+		tz, _, err := ParseTimeZone(settings.TimeZone)
+		if err != nil {
+			tz = "UTC"
+		}
+		if tz != settings.TimeZone {
+			settings.TimeZone = tz
+			migrated[chatID] = settings
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for chatID, settings := range migrated {
+		if _, err := db.Exec(`UPDATE Settings SET settings = ? WHERE chat_id = ?`,
+			settings.String(), chatID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *SettingsConfig) GetAll() (map[int64]*Settings, error) {
@@ -117,13 +260,19 @@ func (c *SettingsConfig) Get(chatID int64) (*Settings, error) {
 	var s string
 	if err := row.Scan(&s); err != nil {
 		if err == sql.ErrNoRows {
-			return DefaultSettings(), nil
+			return c.Default(), nil
 		}
 		return nil, fmt.Errorf("INTERNAL: retrieving settings for chat id %d: %w", chatID, err)
 	}
 	return SettingsFromString(s), nil
 }
 
+// Default returns settings of new users with the default language of the
+// config.
+func (c *SettingsConfig) Default() *Settings {
+	return newSettings(c.Languages.Default())
+}
+
 func (c *SettingsConfig) Set(chatID int64, s *Settings) error {
 	_, err := c.db.Exec(`
 		INSERT OR REPLACE INTO Settings(chat_id, settings) VALUES
@@ -136,44 +285,145 @@ func (c *SettingsConfig) Set(chatID int64, s *Settings) error {
 }
 
 func (c *SettingsConfig) ValidateLanguage(language string) error {
-	_, ok := SupportedInputLanguages[language]
-	if !ok {
+	if _, ok := c.Languages.Get(language); !ok {
 		return fmt.Errorf("unsupported language %q", language)
 	}
 	return nil
 }
 
+// SetLanguage changes the input language. Translation languages chosen by the
+// user are kept, the language's default ones are added unless the user turned
+// them off.
 func (c *SettingsConfig) SetLanguage(chatid int64, language string) error {
 	currentSettings, err := c.Get(chatid)
 	if err != nil {
 		return err
 	}
-	if err := c.ValidateLanguage(language); err != nil {
-		return err
+	lang, ok := c.Languages.Get(language)
+	if !ok {
+		return fmt.Errorf("unsupported language %q", language)
 	}
-	languageSettings := SupportedInputLanguages[language]
-	currentSettings.InputLanguage = languageSettings.InputLanguage
-	currentSettings.InputLanguageISO639_3 = languageSettings.InputLanguageISO639_3
-	currentSettings.TranslationLanguages = languageSettings.TranslationLanguages
+	translations := make(map[string]bool)
+	for l, v := range currentSettings.TranslationLanguages {
+		translations[l] = v
+	}
+	for _, l := range lang.TranslationLanguages {
+		if _, ok := translations[l]; !ok {
+			translations[l] = true
+		}
+	}
+	// Translations into the input language itself are kept, so that the
+	// user's choice is back when switching languages, and skipped in
+	// Translations.
+	currentSettings.InputLanguage = lang.Name
+	currentSettings.InputLanguageISO639_3 = lang.ISO639_3
+	currentSettings.TranslationLanguages = translations
 	return c.Set(chatid, currentSettings)
 }
 
 func (c *SettingsConfig) ValidateTimeZone(tz string) error {
-	set := TimeZones[tz]
-	if !set {
-		return fmt.Errorf("unsupported time zone (format should be UTC, UTC+X or UTC-X)")
+	if _, _, err := ParseTimeZone(tz); err != nil {
+		return fmt.Errorf("%v (format should be a name like Europe/Budapest, UTC, UTC+X or UTC-X:MM)", err)
 	}
 	return nil
 }
 
 func (c *SettingsConfig) SetTimeZone(chatid int64, tz string) error {
-	if err := c.ValidateTimeZone(tz); err != nil {
+	name, _, err := ParseTimeZone(tz)
+	if err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.TimeZone = name
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetScheduler(chatid int64, scheduler string) error {
+	if err := ValidateScheduler(scheduler); err != nil {
+		return err
+	}
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.Scheduler = scheduler
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetIgnoreDiacritics(chatid int64, ignore bool) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.IgnoreDiacritics = ignore
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetDeck(chatid int64, deck string) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.Deck = deck
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetDailyLimits(chatid int64, l DailyLimits) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.NewCardsPerDay = l.NewCards
+	currentSettings.ReviewsPerDay = l.Reviews
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetOrder(chatid int64, order string) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.Order = order
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetReminderWindow(chatid int64, from, to int) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.RemindFromMinutes = from
+	currentSettings.RemindToMinutes = to
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetRemindersPerDay(chatid int64, n int) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
 		return err
 	}
+	currentSettings.RemindersPerDay = n
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetRemindWeekdays(chatid int64, ds []time.Weekday) error {
+	currentSettings, err := c.Get(chatid)
+	if err != nil {
+		return err
+	}
+	currentSettings.RemindWeekdays = ds
+	return c.Set(chatid, currentSettings)
+}
+
+func (c *SettingsConfig) SetPracticeScope(chatid int64, scope Scope) error {
 	currentSettings, err := c.Get(chatid)
 	if err != nil {
 		return err
 	}
-	currentSettings.TimeZone = tz
+	currentSettings.PracticeDeck = scope.Deck
+	currentSettings.PracticeTag = scope.Tag
 	return c.Set(chatid, currentSettings)
 }
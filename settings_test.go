@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestSettings(t *testing.T) {
@@ -67,3 +68,93 @@ func TestSettings(t *testing.T) {
 		t.Errorf("settings.GetAll() got: %v want: %v", gotAll, wantAll)
 	}
 }
+
+func TestSettingsLocation(t *testing.T) {
+	now := time.Date(2020, 6, 10, 12, 0, 0, 0, time.UTC)
+	for tz, want := range map[string]int{
+		"":       12,
+		"UTC":    12,
+		"UTC+3":  15,
+		"UTC-11": 1,
+		"bogus":  12,
+		// Summer time in Budapest is UTC+2.
+		"Europe/Budapest": 14,
+		"UTC+5:30":        17,
+		"UTC+14":          2,
+	} {
+		if got := now.In((&Settings{TimeZone: tz}).Location()).Hour(); got != want {
+			t.Errorf("%q: got hour %d; want %d", tz, got, want)
+		}
+	}
+}
+
+func TestMigrateTimeZones(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	db := filepath.Join(dir, "tmpdb")
+	settings, err := NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := map[int64]string{0: "UTC+3", 1: "UTC+0", 2: "", 3: "Europe/Budapest", 4: "UTC+99", 5: "Mars/Olympus"}
+	for chatID, tz := range stored {
+		s := DefaultSettings()
+		s.TimeZone = tz
+		if err := settings.Set(chatID, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Migration runs when the database is opened.
+	settings, err = NewSettingsConfig(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Names which can't be loaded are kept, the host might lack the zone.
+	want := map[int64]string{0: "UTC+3", 1: "UTC", 2: "UTC", 3: "Europe/Budapest", 4: "UTC", 5: "Mars/Olympus"}
+	for chatID, tz := range want {
+		s, err := settings.Get(chatID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.TimeZone != tz {
+			t.Errorf("chat %d: got time zone %q; want %q", chatID, s.TimeZone, tz)
+		}
+	}
+}
+
+func TestSettingsBeforeReminderWindows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repetition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	settings, err := NewSettingsConfig(filepath.Join(dir, "tmpdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Settings as they were saved before reminder windows were introduced.
+	const chatID int64 = 0
+	if _, err := settings.db.Exec(`INSERT INTO Settings(chat_id, settings) VALUES (?, ?)`, chatID,
+		`{"InputLanguage":"Hungarian","InputLanguageISO639_3":"hun","TranslationLanguages":{"eng":true},"TimeZone":"UTC"}`); err != nil {
+		t.Fatal(err)
+	}
+	s, err := settings.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RemindFromMinutes != 8*60 || s.RemindToMinutes != 21*60 {
+		t.Errorf("got window %d-%d; want 480-1260", s.RemindFromMinutes, s.RemindToMinutes)
+	}
+	midnight := time.Date(2020, 5, 4, 0, 0, 0, 0, time.UTC)
+	if s.ShouldRemind(time.Unix(0, 0), midnight) {
+		t.Errorf("ShouldRemind at midnight: got true; want false")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Implementation of SuperMemo 2 algorithm.
+// See https://www.supermemo.com/en/archives1990-2015/english/ol/sm2
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	sm2InitialEase = 2.5
+	sm2MinEase     = 1.3
+	// Hard answers shrink the interval, easy ones give it a boost on top of
+	// ease factor changes.
+	sm2HardFactor = 0.5
+	sm2EasyBonus  = 1.3
+	day           = 24 * time.Hour
+)
+
+type sm2Scheduler struct {
+	// Forgotten cards are shown again after relearn duration.
+	relearn time.Duration
+}
+
+// sm2Quality maps grades to the SM-2 quality of response (0-5).
+func sm2Quality(g Grade) float64 {
+	switch g {
+	case GradeHard:
+		return 3
+	case GradeGood:
+		return 4
+	case GradeEasy:
+		return 5
+	}
+	return 1
+}
+
+// Schedule returns the state of the card after it was answered with the grade.
+// SM-2 keeps all it needs in the card, so history is not used.
+func (s sm2Scheduler) Schedule(c Card, _ []Review, g Grade, _ time.Time) Card {
+	if c.Ease < sm2MinEase {
+		c.Ease = sm2InitialEase
+	}
+	if g == GradeAgain {
+		c.Lapses++
+		c.Stage = 0
+		c.Interval = s.relearn
+		return c
+	}
+
+	q := sm2Quality(g)
+	c.Ease = math.Max(sm2MinEase, c.Ease+0.1-(5-q)*(0.08+(5-q)*0.02))
+	c.Stage++
+	switch c.Stage {
+	case 1:
+		c.Interval = day
+	case 2:
+		c.Interval = 6 * day
+	default:
+		c.Interval = time.Duration(float64(c.Interval) * c.Ease)
+	}
+	switch g {
+	case GradeHard:
+		c.Interval = time.Duration(float64(c.Interval) * sm2HardFactor)
+	case GradeEasy:
+		c.Interval = time.Duration(float64(c.Interval) * sm2EasyBonus)
+	}
+	if c.Interval < day {
+		c.Interval = day
+	}
+	// Round to whole seconds as that's how intervals are stored.
+	c.Interval = c.Interval.Round(time.Second)
+	return c
+}
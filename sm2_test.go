@@ -0,0 +1,65 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSM2(t *testing.T) {
+	const relearn = 10 * time.Minute
+	s := sm2Scheduler{relearn}
+	next := func(c Card, g Grade) Card {
+		return s.Schedule(c, nil, g, time.Now())
+	}
+	c := Card{Ease: sm2InitialEase}
+
+	for _, want := range []time.Duration{day, 6 * day, 15 * day} {
+		c = next(c, GradeGood)
+		if c.Interval != want {
+			t.Errorf("Good at stage %d: got interval %v; want %v", c.Stage, c.Interval, want)
+		}
+	}
+	if c.Ease != sm2InitialEase {
+		t.Errorf("got ease %v after good answers; want %v", c.Ease, sm2InitialEase)
+	}
+
+	hard := next(c, GradeHard)
+	good := next(c, GradeGood)
+	easy := next(c, GradeEasy)
+	if !(hard.Interval < good.Interval && good.Interval < easy.Interval) {
+		t.Errorf("got intervals hard %v, good %v, easy %v; want increasing", hard.Interval, good.Interval, easy.Interval)
+	}
+	if !(hard.Ease < good.Ease && good.Ease < easy.Ease) {
+		t.Errorf("got ease hard %v, good %v, easy %v; want increasing", hard.Ease, good.Ease, easy.Ease)
+	}
+
+	again := next(c, GradeAgain)
+	want := Card{Stage: 0, Ease: c.Ease, Interval: relearn, Lapses: 1}
+	if again != want {
+		t.Errorf("Again: got %+v; want %+v", again, want)
+	}
+	// Failing a card that wasn't learned yet is a lapse too.
+	if again = next(again, GradeAgain); again.Lapses != 2 {
+		t.Errorf("got %d lapses; want 2", again.Lapses)
+	}
+
+	for i := 0; i < 10; i++ {
+		c = next(c, GradeHard)
+	}
+	if c.Ease != sm2MinEase {
+		t.Errorf("got ease %v after many hard answers; want %v", c.Ease, sm2MinEase)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Typed-answer practice, where user has to spell the word.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var diacriticsReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a", "ą", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e", "ė", "e", "ę", "e", "ě", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i", "į", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ő", "o", "ø", "o", "ō", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ű", "u", "ū", "u", "ů", "u", "ų", "u",
+	"ý", "y", "ÿ", "y",
+	"ç", "c", "ć", "c", "č", "c",
+	"ď", "d", "ğ", "g", "ł", "l", "ľ", "l", "ĺ", "l",
+	"ñ", "n", "ń", "n", "ň", "n", "ř", "r", "ŕ", "r",
+	"ś", "s", "š", "s", "ş", "s", "ß", "ss",
+	"ť", "t", "ţ", "t", "ź", "z", "ż", "z", "ž", "z",
+)
+
+// normalizeSpelling makes comparison case insensitive and optionally ignores
+// diacritics.
+func normalizeSpelling(s string, ignoreDiacritics bool) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if ignoreDiacritics {
+		s = diacriticsReplacer.Replace(s)
+	}
+	return s
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// spellingDiff shows a single edit that turns the answer into the word, e.g.
+// "fek[+e]te", "fek[-x]ete" or "feket[a→e]". The answer is shown as it was
+// typed apart from the case, even if diacritics are ignored. Answer and word
+// should be at distance 1.
+func spellingDiff(answer, word string, ignoreDiacritics bool) string {
+	got := []rune(normalizeSpelling(answer, false))
+	want := []rune(normalizeSpelling(word, false))
+	same := func(a, b rune) bool {
+		return normalizeSpelling(string(a), ignoreDiacritics) == normalizeSpelling(string(b), ignoreDiacritics)
+	}
+	p := 0
+	for p < len(got) && p < len(want) && same(got[p], want[p]) {
+		p++
+	}
+	s := 0
+	for s < len(got)-p && s < len(want)-p && same(got[len(got)-1-s], want[len(want)-1-s]) {
+		s++
+	}
+	switch g, w := len(got)-p-s, len(want)-p-s; {
+	case g == 0 && w == 1:
+		return fmt.Sprintf("%s[+%c]%s", string(got[:p]), want[p], string(got[p:]))
+	case g == 1 && w == 0:
+		return fmt.Sprintf("%s[-%c]%s", string(got[:p]), got[p], string(got[p+1:]))
+	case g == 1 && w == 1:
+		return fmt.Sprintf("%s[%c→%c]%s", string(got[:p]), got[p], want[p], string(got[p+1:]))
+	}
+	// Letters replaced with several ones, e.g. "ß" with "ss", are a single
+	// edit only without diacritics.
+	if ignoreDiacritics {
+		return spellingDiff(normalizeSpelling(answer, true), normalizeSpelling(word, true), false)
+	}
+	return ""
+}
+
+// SpellingResult is the outcome of comparing typed answer to the word.
+type SpellingResult struct {
+	Grade Grade
+	// Diff is set for almost correct answers.
+	Diff string
+}
+
+// CheckSpelling grades the answer: correct answers are Good, ones with a single
+// typo are Hard and the rest is Again.
+func CheckSpelling(word, answer string, ignoreDiacritics bool) SpellingResult {
+	w := []rune(normalizeSpelling(word, ignoreDiacritics))
+	a := []rune(normalizeSpelling(answer, ignoreDiacritics))
+	switch levenshtein(a, w) {
+	case 0:
+		return SpellingResult{Grade: GradeGood}
+	case 1:
+		return SpellingResult{Grade: GradeHard, Diff: spellingDiff(answer, word, ignoreDiacritics)}
+	}
+	return SpellingResult{Grade: GradeAgain}
+}
+
+// spellingCommand asks user to type words by their definitions.
+type spellingCommand struct {
+	name string
+	// Word that is being asked, empty if none.
+	word string
+	// When the word was asked.
+	shown time.Time
+}
+
+// Make sure all fields are Public, otherwise encoding will not work
+type spellingCommandSerialized struct {
+	Word         string
+	ShownSeconds int64
+}
+
+func (c *spellingCommand) Serialize() *SerializedCommand {
+	cs := &spellingCommandSerialized{
+		Word:         c.word,
+		ShownSeconds: c.shown.Unix(),
+	}
+	b, err := json.Marshal(cs)
+	if err != nil {
+		log.Printf("INTERNAL ERROR: Couldn't serialize %v: %v", cs, err)
+	}
+	return &SerializedCommand{
+		Name: c.name,
+		Data: b,
+	}
+}
+
+func (c *spellingCommand) Init(s *SerializedCommand) error {
+	cs := &spellingCommandSerialized{}
+	if err := json.Unmarshal(s.Data, cs); err != nil {
+		return fmt.Errorf("Unmarshal(%s): %w", s.Data, err)
+	}
+	c.word = cs.Word
+	c.shown = time.Unix(cs.ShownSeconds, 0)
+	return nil
+}
+
+func (c *spellingCommand) OnCommand(s *State, m *Message) (Command, error) {
+	return c.askNext(s, m.Chat.Id)
+}
+
+// askNext sends masked definition of the next word due for practice.
+func (c *spellingCommand) askNext(s *State, chatID int64) (Command, error) {
+	scope, err := s.scope(chatID)
+	if err != nil {
+		return nil, err
+	}
+	word, question, err := s.Repetitions.Repeat(chatID, Reverse, scope)
+	if err == sql.ErrNoRows {
+		return nil, s.Telegram.SendTextMessage(chatID, "No more rows to practice; exiting practice mode.")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retrieving word for repetition: %w", err)
+	}
+	if err := s.Telegram.SendTextMessage(chatID, question+"\n\nType the word."); err != nil {
+		return nil, err
+	}
+	c.word = word
+	c.shown = time.Now()
+	return c, nil
+}
+
+func (c *spellingCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	if c.word == "" {
+		return nil, fmt.Errorf("INTERNAL ERROR: spelling practice without a word")
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return nil, err
+	}
+	r := CheckSpelling(c.word, m.Text, settings.IgnoreDiacritics)
+	if err := s.answer(chatID, c.word, Reverse, r.Grade, c.shown); err != nil {
+		return nil, err
+	}
+	var msg string
+	switch r.Grade {
+	case GradeGood:
+		msg = fmt.Sprintf("Correct: %q", c.word)
+	case GradeHard:
+		msg = fmt.Sprintf("Almost: %s\nCorrect: %q", r.Diff, c.word)
+	default:
+		msg = fmt.Sprintf("Wrong. Correct: %q", c.word)
+	}
+	if err := s.Telegram.SendTextMessage(chatID, msg); err != nil {
+		return nil, err
+	}
+	return c.askNext(s, chatID)
+}
+
+func SpellingCommandFactory() CommandFactory {
+	return func(name string) Command {
+		return &spellingCommand{name: name}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCheckSpelling(t *testing.T) {
+	for _, tc := range []struct {
+		word, answer     string
+		ignoreDiacritics bool
+		want             SpellingResult
+	}{
+		{"fekete", "fekete", false, SpellingResult{Grade: GradeGood}},
+		{"Fekete", " fekete ", false, SpellingResult{Grade: GradeGood}},
+		{"fekete", "fekte", false, SpellingResult{Grade: GradeHard, Diff: "fek[+e]te"}},
+		{"fekete", "fekxete", false, SpellingResult{Grade: GradeHard, Diff: "fek[-x]ete"}},
+		{"fekete", "feketa", false, SpellingResult{Grade: GradeHard, Diff: "feket[a→e]"}},
+		{"fekete", "fehér", false, SpellingResult{Grade: GradeAgain}},
+		{"szőlő", "szolo", false, SpellingResult{Grade: GradeAgain}},
+		{"szőlő", "szolo", true, SpellingResult{Grade: GradeGood}},
+		{"szőlő", "szöló", true, SpellingResult{Grade: GradeGood}},
+		{"szőlő", "szőlö", false, SpellingResult{Grade: GradeHard, Diff: "szől[ö→ő]"}},
+		// Diff shows what was typed.
+		{"szőlő", "Szolx", true, SpellingResult{Grade: GradeHard, Diff: "szol[x→ő]"}},
+		{"szőlő", "szölő", true, SpellingResult{Grade: GradeGood}},
+		{"szőlő", "szöl", true, SpellingResult{Grade: GradeHard, Diff: "szöl[+ő]"}},
+		{"straße", "strase", true, SpellingResult{Grade: GradeHard, Diff: "stra[s→ß]e"}},
+		{"straße", "strassee", true, SpellingResult{Grade: GradeHard, Diff: "strasse[-e]"}},
+	} {
+		if got := CheckSpelling(tc.word, tc.answer, tc.ignoreDiacritics); got != tc.want {
+			t.Errorf("CheckSpelling(%q, %q, %v) = %+v; want %+v", tc.word, tc.answer, tc.ignoreDiacritics, got, tc.want)
+		}
+	}
+}
+
+func TestSpellingCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spelling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	if err := c.Repetitions.Save(chatID, "", "fekete", "fekete\n\nblack"); err != nil {
+		t.Fatal(err)
+	}
+	m := &Message{Text: "/spell"}
+	m.Chat.Id = chatID
+	if err := c.Update(&Update{Message: m}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fk.messages[len(fk.messages)-1].Text, "black\n\nType the word."; got != want {
+		t.Errorf("got question %q; want %q", got, want)
+	}
+
+	// The asked word survives a restart.
+	sc, err := c.Commands.Load(chatID)
+	if err != nil || sc == nil || sc.Name != "/spell" {
+		t.Fatalf("Load: got %+v, %v; want /spell", sc, err)
+	}
+	cmd, err := sc.AsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spell, ok := cmd.(*spellingCommand)
+	if !ok || spell.word != "fekete" || spell.shown.IsZero() {
+		t.Fatalf("got command %+v; want spelling of fekete with the time it was shown", cmd)
+	}
+
+	c, fk2 := startTestCommander(t, dir)
+	defer fk2.server.Close()
+	m = &Message{Text: "Fekte"}
+	m.Chat.Id = chatID
+	if err := c.Update(&Update{Message: m}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fk2.messages) == 0 {
+		t.Fatal("got no reply to the answer")
+	}
+	if got, want := fk2.messages[0].Text, "Almost: fek[+e]te\nCorrect: \"fekete\""; got != want {
+		t.Errorf("got reply %q; want %q", got, want)
+	}
+	h, err := c.Repetitions.History(chatID, "fekete", Reverse)
+	if err != nil || len(h) != 1 || h[0].Grade != GradeHard {
+		t.Errorf("History(fekete, Reverse): got %+v, %v; want a single hard review", h, err)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	forecastDays    = 7
+	retentionPeriod = 30 * day
+)
+
+// Stats is a summary of the user's progress.
+type Stats struct {
+	Cards int
+	// Number of cards for each stage.
+	ByStage map[int]int
+	// Number of cards due until the end of today, including overdue ones.
+	DueToday int
+	// DueNextDays[i] is the number of cards due i+1 days after today.
+	DueNextDays []int
+	// First day of DueNextDays, used for labels.
+	Tomorrow     time.Time
+	ReviewsToday int
+	// Share of answers to already learned cards that were remembered during
+	// the retention period. NaN if there were no such answers.
+	Retention        float64
+	RetentionReviews int
+	// Number of consecutive days with reviews, up to today. Today counts only
+	// if there were reviews, so that the streak isn't lost before the day is
+	// over.
+	Streak int
+}
+
+// startOfDay returns midnight of the t's day in loc.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// Stats calculates statistics for the chat. Days are counted in loc.
+func (r *Repetition) Stats(chatID int64, loc *time.Location, now time.Time) (*Stats, error) {
+	today := startOfDay(now, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+	s := &Stats{
+		ByStage:     make(map[int]int),
+		DueNextDays: make([]int, forecastDays),
+		Tomorrow:    tomorrow,
+		Retention:   math.NaN(),
+	}
+
+	rows, err := r.db.Query(`
+		SELECT stage, due_seconds, suspended, buried_until_seconds
+		FROM Repetition
+		WHERE chat_id = $0`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving cards for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			stage       int
+			due, buried int64
+			suspended   bool
+		)
+		if err := rows.Scan(&stage, &due, &suspended, &buried); err != nil {
+			return nil, err
+		}
+		s.Cards++
+		s.ByStage[stage]++
+		// Suspended words aren't due, buried ones are due once they are
+		// back, the same as for practice.
+		if suspended {
+			continue
+		}
+		if buried > due {
+			due = buried
+		}
+		dt := time.Unix(due, 0)
+		if dt.Before(tomorrow) {
+			s.DueToday++
+			continue
+		}
+		for i := range s.DueNextDays {
+			if dt.Before(tomorrow.AddDate(0, 0, i+1)) {
+				s.DueNextDays[i]++
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(`
+		SELECT grade, reviewed_seconds, prev_stage
+		FROM Reviews
+		WHERE chat_id = $0
+		ORDER BY reviewed_seconds DESC`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving reviews for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var remembered int
+	// Start of the next day that has to have reviews to continue the streak.
+	streakDay := today
+	for rows.Next() {
+		var (
+			g    Grade
+			secs int64
+			// NULL for reviews logged before stages were recorded.
+			prevStage sql.NullInt64
+		)
+		if err := rows.Scan(&g, &secs, &prevStage); err != nil {
+			return nil, err
+		}
+		t := time.Unix(secs, 0)
+		if !t.Before(today) {
+			s.ReviewsToday++
+		}
+		if now.Sub(t) <= retentionPeriod && prevStage.Int64 > 0 {
+			s.RetentionReviews++
+			if g != GradeAgain {
+				remembered++
+			}
+		}
+		d := startOfDay(t, loc)
+		if d.Equal(streakDay) {
+			s.Streak++
+			streakDay = streakDay.AddDate(0, 0, -1)
+		} else if s.Streak == 0 && d.Equal(today.AddDate(0, 0, -1)) {
+			// No reviews today yet, but the streak from yesterday continues.
+			s.Streak++
+			streakDay = d.AddDate(0, 0, -1)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if s.RetentionReviews > 0 {
+		s.Retention = float64(remembered) / float64(s.RetentionReviews)
+	}
+	return s, nil
+}
+
+// histogram renders labeled bars scaled to fit into width characters.
+func histogram(labels []string, values []int, width int) string {
+	max, lw, vw := 0, 0, 0
+	for i, v := range values {
+		if v > max {
+			max = v
+		}
+		if len(labels[i]) > lw {
+			lw = len(labels[i])
+		}
+		if l := len(fmt.Sprint(v)); l > vw {
+			vw = l
+		}
+	}
+	var b strings.Builder
+	for i, v := range values {
+		n := 0
+		if max > 0 {
+			n = int(math.Ceil(float64(v) * float64(width) / float64(max)))
+		}
+		l := fmt.Sprintf("%-*s %*d %s", lw, labels[i], vw, v, strings.Repeat("#", n))
+		b.WriteString(strings.TrimRight(l, " ") + "\n")
+	}
+	return b.String()
+}
+
+// Format renders statistics as a MarkdownV2 message.
+func (s *Stats) Format() string {
+	const width = 20
+	var b strings.Builder
+	fmt.Fprintf(&b, "Cards: %d\n", s.Cards)
+	fmt.Fprintf(&b, "Reviews today: %d\n", s.ReviewsToday)
+	if s.RetentionReviews > 0 {
+		fmt.Fprintf(&b, "Retention (30 days): %.0f%% of %d reviews\n", s.Retention*100, s.RetentionReviews)
+	} else {
+		b.WriteString("Retention (30 days): no reviews yet\n")
+	}
+	fmt.Fprintf(&b, "Streak: %d days\n", s.Streak)
+
+	b.WriteString("\nDue\n")
+	labels := []string{"Today"}
+	values := []int{s.DueToday}
+	for i, v := range s.DueNextDays {
+		labels = append(labels, s.Tomorrow.AddDate(0, 0, i).Format("Mon 02"))
+		values = append(values, v)
+	}
+	b.WriteString(histogram(labels, values, width))
+
+	b.WriteString("\nCards by stage\n")
+	var stages []int
+	for st := range s.ByStage {
+		stages = append(stages, st)
+	}
+	sort.Ints(stages)
+	labels, values = nil, nil
+	for _, st := range stages {
+		labels = append(labels, fmt.Sprint(st))
+		values = append(values, s.ByStage[st])
+	}
+	b.WriteString(histogram(labels, values, width))
+
+	// Inside pre-formatted block only ` and \ need escaping, neither can be
+	// present in the text above.
+	return "*Statistics*\n```\n" + b.String() + "```"
+}
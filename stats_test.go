@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	db := filepath.Join(dir, "tmpdb")
+	r, err := NewRepetition(db, []time.Duration{0, time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const chatID int64 = 1
+	loc := (&Settings{TimeZone: "UTC+2"}).Location()
+	// 23:30 local time, 21:30 UTC.
+	now := time.Date(2020, 6, 10, 23, 30, 0, 0, loc)
+
+	cards := []struct {
+		word  string
+		stage int
+		due   time.Time
+	}{
+		{"overdue", 0, now.Add(-48 * time.Hour)},
+		{"today", 1, now.Add(20 * time.Minute)},
+		{"tomorrow", 1, now.Add(time.Hour)},
+		{"in3days", 2, now.Add(3 * day)},
+		{"later", 5, now.Add(30 * day)},
+		{"suspended", 0, now.Add(-48 * time.Hour)},
+		{"buried", 1, now.Add(-48 * time.Hour)},
+	}
+	for _, c := range cards {
+		if _, err := r.db.Exec(`
+			INSERT INTO Repetition(chat_id, word, definition, stage, last_updated_seconds, interval_seconds, due_seconds)
+			VALUES($0, $1, $2, $3, $4, $5, $6)`,
+			chatID, c.word, "def", c.stage, now.Unix(), 0, c.due.Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Suspend(chatID, "suspended", true); err != nil {
+		t.Fatal(err)
+	}
+	// Buried words are due once they are back, the day after tomorrow.
+	if err := r.Bury(chatID, "buried", now.Add(36*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	reviews := []struct {
+		at        time.Time
+		grade     Grade
+		prevStage int
+	}{
+		// Today, local time.
+		{now.Add(-23 * time.Hour), GradeGood, 1},
+		{now.Add(-time.Hour), GradeAgain, 2},
+		// Yesterday.
+		{now.Add(-30 * time.Hour), GradeGood, 0},
+		// Two days ago.
+		{now.Add(-2 * day), GradeEasy, 3},
+		// Gap of a day, streak is broken.
+		{now.Add(-4 * day), GradeGood, 1},
+		// Out of the retention period.
+		{now.Add(-40 * day), GradeAgain, 1},
+	}
+	for _, rv := range reviews {
+		if _, err := r.db.Exec(`
+			INSERT INTO Reviews(chat_id, word, grade, reviewed_seconds, prev_stage)
+			VALUES($0, $1, $2, $3, $4)`,
+			chatID, "today", rv.grade, rv.at.Unix(), rv.prevStage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := r.Stats(chatID, loc, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Stats{
+		Cards:            7,
+		ByStage:          map[int]int{0: 2, 1: 3, 2: 1, 5: 1},
+		DueToday:         2,
+		DueNextDays:      []int{1, 1, 1, 0, 0, 0, 0},
+		Tomorrow:         time.Date(2020, 6, 11, 0, 0, 0, 0, loc),
+		ReviewsToday:     2,
+		Retention:        0.75,
+		RetentionReviews: 4,
+		Streak:           3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	msg := got.Format()
+	for _, s := range []string{"Cards: 7", "Retention (30 days): 75% of 4 reviews", "Streak: 3 days", "Thu 11 1 ##########\nFri 12 1 ##########\n"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("Format() = %q; doesn't contain %q", msg, s)
+		}
+	}
+
+	// Streak continues from yesterday if there were no reviews today yet.
+	got, err = r.Stats(chatID, loc, now.Add(day))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Streak != 3 || got.ReviewsToday != 0 {
+		t.Errorf("next day: got streak %d, reviews today %d; want 3, 0", got.Streak, got.ReviewsToday)
+	}
+
+	empty, err := r.Stats(chatID+1, loc, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Cards != 0 || !math.IsNaN(empty.Retention) || empty.Streak != 0 {
+		t.Errorf("got %+v for empty chat", empty)
+	}
+	if !strings.Contains(empty.Format(), "no reviews yet") {
+		t.Errorf("Format() = %q for empty chat", empty.Format())
+	}
+}
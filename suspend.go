@@ -0,0 +1,109 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Suspended words are never practiced until they are unsuspended, buried ones
+// are hidden until the next day.
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Suspend excludes the word from practice, or includes it back.
+func (r *Repetition) Suspend(chatID int64, word string, suspended bool) error {
+	if _, err := r.db.Exec(`
+		UPDATE Repetition
+		SET suspended = $0
+		WHERE word = $1
+		  AND chat_id = $2`,
+		suspended, word, chatID); err != nil {
+		return fmt.Errorf("INTERNAL: suspending %q: %w", word, err)
+	}
+	return nil
+}
+
+// Bury hides the word from practice until the time.
+func (r *Repetition) Bury(chatID int64, word string, until time.Time) error {
+	if _, err := r.db.Exec(`
+		UPDATE Repetition
+		SET buried_until_seconds = $0
+		WHERE word = $1
+		  AND chat_id = $2`,
+		until.Unix(), word, chatID); err != nil {
+		return fmt.Errorf("INTERNAL: burying %q: %w", word, err)
+	}
+	return nil
+}
+
+// Suspended returns suspended words of the chat ordered alphabetically.
+func (r *Repetition) Suspended(chatID int64) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT word
+		FROM Repetition
+		WHERE chat_id = $0
+		  AND suspended
+		ORDER BY word`,
+		chatID)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving suspended words for chat %d: %w", chatID, err)
+	}
+	defer rows.Close()
+	var ws []string
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
+		}
+		ws = append(ws, w)
+	}
+	return ws, rows.Err()
+}
+
+// startOfTomorrow returns the start of the next day in the user's time zone.
+func startOfTomorrow(s *State, chatID int64, now time.Time) (time.Time, error) {
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return startOfDay(now, settings.Location()).AddDate(0, 0, 1), nil
+}
+
+// suspendedKeyboard has a button to unsuspend each of the words.
+func suspendedKeyboard(words []string) [][]*InlineKeyboard {
+	ks := [][]*InlineKeyboard{}
+	for _, w := range words {
+		ks = append(ks, []*InlineKeyboard{UnsuspendCallback{w}.AsInlineKeyboard()})
+	}
+	return ks
+}
+
+// suspendedReply lists suspended words with buttons to unsuspend them.
+func suspendedReply(s *State, chatID int64) error {
+	ws, err := s.Repetitions.Suspended(chatID)
+	if err != nil {
+		return err
+	}
+	if len(ws) == 0 {
+		return s.Telegram.SendTextMessage(chatID, "There are no suspended words.")
+	}
+	return s.Telegram.SendMessage(&MessageReply{
+		ChatId: chatID,
+		Text:   fmt.Sprintf("Suspended words: %d. They aren't practiced until unsuspended.", len(ws)),
+		ReplyMarkup: &ReplyMarkup{
+			InlineKeyboard: suspendedKeyboard(ws),
+		},
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuspendAndBury(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suspend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRepetition(filepath.Join(dir, "tmpdb"), []time.Duration{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"fut", "kutya", "ló"} {
+		if err := r.Save(chatID, "", w, w+" is something"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Suspend(chatID, "ló", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Suspend(chatID, "fut", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Suspended(chatID); err != nil || !reflect.DeepEqual(got, []string{"fut", "ló"}) {
+		t.Errorf("Suspended: %q, %v want [fut ló], nil", got, err)
+	}
+	if err := r.Suspend(chatID, "fut", false); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if err := r.Bury(chatID, "kutya", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.DueWords(chatID, Forward, Scope{}); err != nil || !reflect.DeepEqual(got, []string{"fut"}) {
+		t.Errorf("DueWords with buried kutya: %q, %v want [fut], nil", got, err)
+	}
+	// The next day buried words are back.
+	if err := r.Bury(chatID, "kutya", now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.DueWords(chatID, Forward, Scope{}); err != nil || !reflect.DeepEqual(got, []string{"fut", "kutya"}) {
+		t.Errorf("DueWords after burial: %q, %v want [fut kutya], nil", got, err)
+	}
+}
+
+func TestReversePracticeBury(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suspend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	for _, w := range []string{"fut", "kutya"} {
+		if err := c.Repetitions.Save(chatID, "", w, w+"\n\nsomething"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &Message{Text: "/reverse"}
+	m.Chat.Id = chatID
+	if err := c.Update(&Update{Message: m}); err != nil {
+		t.Fatal(err)
+	}
+	// Buried and suspended words are followed by the next reverse card.
+	for _, b := range []string{"Bury", "Suspend"} {
+		if got := fk.messages[len(fk.messages)-1].Text; !strings.HasSuffix(got, "Recall the word.") {
+			t.Fatalf("got message %q before pressing %s; want a reverse card", got, b)
+		}
+		if err := fk.PressButton(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.PollAndProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "No more rows to practice; exiting practice mode."
+	if got := fk.messages[len(fk.messages)-1].Text; got != want {
+		t.Errorf("got last message %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Free-form tags of the saved words, e.g. "verbs" or "chapter-3".
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// normalizeTag makes tags "#Verbs" and "verbs" the same.
+func normalizeTag(t string) string {
+	return strings.ToLower(strings.TrimLeft(strings.TrimSpace(t), "#"))
+}
+
+// ParseTags extracts tags separated by spaces or commas.
+func ParseTags(s string) []string {
+	var ts []string
+	seen := make(map[string]bool)
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		t := normalizeTag(f)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// AddTags attaches tags to the word.
+func (r *Repetition) AddTags(chatID int64, word string, tags []string) error {
+	for _, t := range tags {
+		if _, err := r.db.Exec(`
+			INSERT OR IGNORE INTO Tags(chat_id, word, tag)
+			VALUES($0, $1, $2)`,
+			chatID, word, t); err != nil {
+			return fmt.Errorf("INTERNAL: tagging %q with %q: %w", word, t, err)
+		}
+	}
+	return nil
+}
+
+// RemoveTags detaches tags from the word. Tags which the word doesn't have are
+// ignored.
+func (r *Repetition) RemoveTags(chatID int64, word string, tags []string) error {
+	for _, t := range tags {
+		if _, err := r.db.Exec(`
+			DELETE FROM Tags
+			WHERE chat_id = $0
+			  AND word = $1
+			  AND tag = $2`,
+			chatID, word, t); err != nil {
+			return fmt.Errorf("INTERNAL: removing tag %q of %q: %w", t, word, err)
+		}
+	}
+	return nil
+}
+
+// Tags returns sorted tags of the word.
+func (r *Repetition) Tags(chatID int64, word string) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT tag
+		FROM Tags
+		WHERE chat_id = $0
+		  AND word = $1
+		ORDER BY tag`,
+		chatID, word)
+	if err != nil {
+		return nil, fmt.Errorf("INTERNAL: retrieving tags of %q: %w", word, err)
+	}
+	defer rows.Close()
+	var ts []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, rows.Err()
+}
+
+// tagCommand attaches tags to a saved word. Either both are passed as
+// arguments, e.g. "/tag fekete colors adjectives", or tags are asked for.
+// "/tag remove fekete colors" detaches tags.
+type tagCommand struct {
+	name string
+	// Word which tags are asked for, empty if none.
+	word string
+}
+
+// Make sure all fields are Public, otherwise encoding will not work
+type tagCommandSerialized struct {
+	Word string
+}
+
+func (c *tagCommand) Serialize() *SerializedCommand {
+	cs := &tagCommandSerialized{Word: c.word}
+	b, err := json.Marshal(cs)
+	if err != nil {
+		log.Printf("INTERNAL ERROR: Couldn't serialize %v: %v", cs, err)
+	}
+	return &SerializedCommand{
+		Name: c.name,
+		Data: b,
+	}
+}
+
+func (c *tagCommand) Init(s *SerializedCommand) error {
+	cs := &tagCommandSerialized{}
+	if err := json.Unmarshal(s.Data, cs); err != nil {
+		return fmt.Errorf("Unmarshal(%s): %w", s.Data, err)
+	}
+	c.word = cs.Word
+	return nil
+}
+
+// ask asks for the tags of the word.
+func (c *tagCommand) ask(s *State, chatID int64) error {
+	ts, err := s.Repetitions.Tags(chatID, c.word)
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Enter tags for %q separated by spaces.", c.word)
+	if len(ts) > 0 {
+		msg += fmt.Sprintf("\nCurrent tags: #%s", strings.Join(ts, " #"))
+	}
+	return s.Telegram.SendTextMessage(chatID, msg)
+}
+
+// save attaches tags to the word and reports all its tags.
+func (c *tagCommand) save(s *State, chatID int64, tags []string) error {
+	if len(tags) == 0 {
+		return UserError{ChatID: chatID, Err: fmt.Errorf("No tags were entered.")}
+	}
+	if err := checkSaved(s, chatID, c.word); err != nil {
+		return err
+	}
+	if err := s.Repetitions.AddTags(chatID, c.word, tags); err != nil {
+		return err
+	}
+	return c.reportTags(s, chatID)
+}
+
+// remove detaches tags from the word and reports the remaining ones.
+func (c *tagCommand) remove(s *State, chatID int64, tags []string) error {
+	if err := s.Repetitions.RemoveTags(chatID, c.word, tags); err != nil {
+		return err
+	}
+	return c.reportTags(s, chatID)
+}
+
+func (c *tagCommand) reportTags(s *State, chatID int64) error {
+	ts, err := s.Repetitions.Tags(chatID, c.word)
+	if err != nil {
+		return err
+	}
+	if len(ts) == 0 {
+		return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("%q has no tags.", c.word))
+	}
+	return s.Telegram.SendTextMessage(chatID, fmt.Sprintf("Tags of %q: #%s", c.word, strings.Join(ts, " #")))
+}
+
+const tagUsage = `Usage:
+  /tag <word> <tags> - add tags to the word
+  /tag remove <word> <tags> - remove tags of the word`
+
+func (c *tagCommand) OnCommand(s *State, m *Message) (Command, error) {
+	chatID := m.Chat.Id
+	args := strings.Fields(strings.TrimPrefix(m.Text, c.name))
+	if len(args) == 0 {
+		return nil, s.Telegram.SendTextMessage(chatID, tagUsage)
+	}
+	if args[0] == "remove" {
+		if len(args) < 3 {
+			return nil, s.Telegram.SendTextMessage(chatID, tagUsage)
+		}
+		c.word = args[1]
+		if err := checkSaved(s, chatID, c.word); err != nil {
+			return nil, err
+		}
+		return nil, c.remove(s, chatID, ParseTags(strings.Join(args[2:], " ")))
+	}
+	c.word = args[0]
+	if err := checkSaved(s, chatID, c.word); err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return c, c.ask(s, chatID)
+	}
+	return nil, c.save(s, chatID, ParseTags(strings.Join(args[1:], " ")))
+}
+
+func (c *tagCommand) ProcessMessage(s *State, m *Message) (Command, error) {
+	if c.word == "" {
+		return nil, fmt.Errorf("INTERNAL ERROR: tagging without a word")
+	}
+	ts := ParseTags(m.Text)
+	if len(ts) == 0 {
+		// Let user try again.
+		return c, UserError{ChatID: m.Chat.Id, Err: fmt.Errorf("No tags were entered. Please try again.")}
+	}
+	return nil, c.save(s, m.Chat.Id, ts)
+}
+
+func TagCommandFactory() CommandFactory {
+	return func(name string) Command {
+		return &tagCommand{name: name}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTags(t *testing.T) {
+	got := ParseTags("#Verbs, chapter-3  work,verbs")
+	want := []string{"verbs", "chapter-3", "work"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRepetition(filepath.Join(dir, "tmpdb"), []time.Duration{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chatID int64 = 1
+	for _, w := range []string{"fut", "kutya"} {
+		if err := r.Save(chatID, "", w, w+" is something"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.AddTags(chatID, "fut", []string{"verbs", "chapter-3"}); err != nil {
+		t.Fatal(err)
+	}
+	// Adding the same tag again is fine.
+	if err := r.AddTags(chatID, "fut", []string{"verbs"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Tags(chatID, "fut"); err != nil || !reflect.DeepEqual(got, []string{"chapter-3", "verbs"}) {
+		t.Errorf("Tags(fut): %q, %v want [chapter-3 verbs], nil", got, err)
+	}
+
+	if err := r.RemoveTags(chatID, "fut", []string{"chapter-3", "nouns"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Tags(chatID, "fut"); err != nil || !reflect.DeepEqual(got, []string{"verbs"}) {
+		t.Errorf("Tags(fut) after removing chapter-3: %q, %v want [verbs], nil", got, err)
+	}
+
+	scope := Scope{Tag: "verbs"}
+	if w, err := r.RepeatWord(chatID, Forward, scope); err != nil || w != "fut" {
+		t.Errorf("RepeatWord(#verbs): %q, %v want fut, nil", w, err)
+	}
+	if w, err := r.RepeatWord(chatID, Forward, Scope{Deck: "Other", Tag: "verbs"}); err != sql.ErrNoRows {
+		t.Errorf("RepeatWord(Other #verbs): %q, %v want sql.ErrNoRows", w, err)
+	}
+
+	if err := r.Delete(chatID, "fut"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Tags(chatID, "fut"); err != nil || len(got) != 0 {
+		t.Errorf("Tags(fut) after delete: %q, %v want none", got, err)
+	}
+}
+
+func TestTagCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	send := func(text string) string {
+		t.Helper()
+		m := &Message{Text: text}
+		m.Chat.Id = chatID
+		if err := c.Update(&Update{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+		return fk.messages[len(fk.messages)-1].Text
+	}
+	if err := c.Repetitions.Save(chatID, "", "fut", "fut\n\nto run"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := send("/tag fut verbs chapter-3"), `Tags of "fut": #chapter-3 #verbs`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if got, want := send("/tag remove fut chapter-3"), `Tags of "fut": #verbs`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if got, want := send("/tag remove fut verbs"), `"fut" has no tags.`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// Tag button on the definition of a word which was deleted since.
+	send("fut")
+	if err := c.Repetitions.Delete(chatID, "fut"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fk.PressButton("Tag"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PollAndProcess(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fk.messages[len(fk.messages)-1].Text; !strings.Contains(got, "isn't saved for learning") {
+		t.Errorf("got %q after pressing Tag; want the word isn't saved", got)
+	}
+	send("verbs")
+	if got, err := c.Repetitions.Tags(chatID, "fut"); err != nil || len(got) != 0 {
+		t.Errorf("Tags(fut) of the deleted word: %q, %v want none", got, err)
+	}
+}
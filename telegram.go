@@ -22,16 +22,25 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"time"
 )
 
 // Note that BotToken comes from a file not in a git repository.
 // TODO: should be in the main function. For now hard and var for e2e testing.
 var telegramApiPrefix = "https://api.telegram.org/bot" + BotToken
 
+// Files are downloaded from a separate prefix, see
+// https://core.telegram.org/bots/api#getfile
+var telegramFilePrefix = "https://api.telegram.org/file/bot" + BotToken
+
+// Bots can't download files larger than that.
+const maxFileSize = 20 << 20
+
 func methodURL(m string) string {
 	return telegramApiPrefix + "/" + m
 }
@@ -48,7 +57,44 @@ type Message struct {
 	Chat struct {
 		Id int64 `json:"id"`
 	} `json:"chat"`
+	// Unix time when the message was sent.
+	Date        int64       `json:"date,omitempty"`
 	ReplyMarkup ReplyMarkup `json:"reply_markup"`
+	// Set if the message is a file sent by the user.
+	Document *Document `json:"document,omitempty"`
+	// Set if the user shared their location.
+	Location *Location `json:"location,omitempty"`
+}
+
+// Location is a point on the map, see https://core.telegram.org/bots/api#location
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Document is a general file, see https://core.telegram.org/bots/api#document
+type Document struct {
+	FileId   string `json:"file_id"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	FileSize int64  `json:"file_size,omitempty"`
+}
+
+// File is a file ready to be downloaded, see
+// https://core.telegram.org/bots/api#file
+type File struct {
+	FileId   string `json:"file_id"`
+	FileSize int64  `json:"file_size,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+}
+
+// SentAt returns the time when the message was sent. Zero time is returned if
+// it's unknown.
+func (m *Message) SentAt() time.Time {
+	if m.Date == 0 {
+		return time.Time{}
+	}
+	return time.Unix(m.Date, 0)
 }
 
 type CallbackQuery struct {
@@ -82,6 +128,31 @@ type ReplyMarkup struct {
 	InlineKeyboard [][]*InlineKeyboard `json:"inline_keyboard"`
 }
 
+// KeyboardButton is a button of the keyboard shown instead of the user's one,
+// see https://core.telegram.org/bots/api#keyboardbutton
+type KeyboardButton struct {
+	Text string `json:"text"`
+	// If set, user's location is sent when the button is pressed.
+	RequestLocation bool `json:"request_location,omitempty"`
+}
+
+// ReplyKeyboard either shows the custom keyboard or removes it, see
+// https://core.telegram.org/bots/api#replykeyboardmarkup
+type ReplyKeyboard struct {
+	Keyboard        [][]*KeyboardButton `json:"keyboard,omitempty"`
+	OneTimeKeyboard bool                `json:"one_time_keyboard,omitempty"`
+	ResizeKeyboard  bool                `json:"resize_keyboard,omitempty"`
+	RemoveKeyboard  bool                `json:"remove_keyboard,omitempty"`
+}
+
+// KeyboardMessageReply is a message with the custom keyboard, unlike
+// MessageReply which can only have inline buttons.
+type KeyboardMessageReply struct {
+	ChatId      int64          `json:"chat_id"`
+	Text        string         `json:"text"`
+	ReplyMarkup *ReplyKeyboard `json:"reply_markup"`
+}
+
 type MessageReply struct {
 	ChatId      int64        `json:"chat_id"`
 	Text        string       `json:"text"`
@@ -167,6 +238,11 @@ func (t *Telegram) SendMessage(mr *MessageReply) error {
 	return t.Call("sendMessage", mr, &m)
 }
 
+func (t *Telegram) SendKeyboardMessage(mr *KeyboardMessageReply) error {
+	var m Message
+	return t.Call("sendMessage", mr, &m)
+}
+
 func (t *Telegram) AnswerCallback(id string, text string) error {
 	q := &struct {
 		Id string `json:"callback_query_id"`
@@ -189,8 +265,70 @@ func (t *Telegram) AnswerCallback(id string, text string) error {
 
 func (t *Telegram) AnswerCallbackLog(id string, text string) {
 	if err := t.AnswerCallback(id, text); err != nil {
-		log.Printf("Error answering callback: %w", err)
+		log.Printf("Error answering callback: %v", err)
+	}
+}
+
+// DownloadFile returns content of the file sent to the bot.
+func (t *Telegram) DownloadFile(fileID string) ([]byte, error) {
+	var f File
+	if err := t.Call("getFile", &map[string]interface{}{
+		"file_id": fileID,
+	}, &f); err != nil {
+		return nil, err
+	}
+	if f.FilePath == "" {
+		return nil, fmt.Errorf("file %q can't be downloaded", fileID)
+	}
+	r, err := t.hc.Get(telegramFilePrefix + "/" + f.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code downloading %q: got %d, want 200", f.FilePath, r.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(r.Body, maxFileSize))
+}
+
+// SendDocument sends data as a file with the given name.
+func (t *Telegram) SendDocument(chatId int64, name string, data []byte, caption string) error {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+
+	if err := w.WriteField("chat_id", fmt.Sprint(chatId)); err != nil {
+		return err
 	}
+	if caption != "" {
+		if err := w.WriteField("caption", caption); err != nil {
+			return err
+		}
+	}
+	fw, err := w.CreateFormFile("document", name)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", methodURL("sendDocument"), &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	log.Printf("Calling %q with document %q (%d bytes) for chat %d", "sendDocument", name, len(data), chatId)
+	res, err := t.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var m Message
+	return t.callHandleResponse(res, &m)
 }
 
 func (t *Telegram) SetWebhook(url string, certPath string) error {
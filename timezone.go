@@ -0,0 +1,228 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Time zones are either IANA names, e.g. Europe/Budapest, which follow
+// daylight saving time, or fixed offsets from UTC, e.g. UTC+5:30.
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// Time zone database is built in, so that time zones work on hosts
+	// without one, e.g. in the docker image.
+	_ "time/tzdata"
+)
+
+const (
+	// Fixed offsets which exist in practice are from UTC-12 to UTC+14.
+	minUTCOffset = -12 * time.Hour
+	maxUTCOffset = 14 * time.Hour
+)
+
+// ParseTimeZone validates the time zone and returns its canonical name and
+// location. Accepted are "UTC", offsets like "UTC+3" or "UTC-9:30" and IANA
+// names like "Europe/Budapest".
+func ParseTimeZone(tz string) (string, *time.Location, error) {
+	tz = strings.TrimSpace(tz)
+	if tz == "" || tz == "UTC" {
+		return "UTC", time.UTC, nil
+	}
+	if strings.HasPrefix(tz, "UTC") {
+		return parseUTCOffset(tz)
+	}
+	// Local would depend on where the bot runs.
+	if tz == "Local" {
+		return "", nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return "", nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+	return loc.String(), loc, nil
+}
+
+// locations caches loaded time zones by name, as loading reads the database.
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// parseUTCOffset parses time zones of the form UTC+H or UTC+H:MM.
+func parseUTCOffset(tz string) (string, *time.Location, error) {
+	o := strings.TrimPrefix(tz, "UTC")
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(o, "+"):
+	case strings.HasPrefix(o, "-"):
+		sign = -1
+	default:
+		return "", nil, fmt.Errorf("offset in %q should start with + or -", tz)
+	}
+	hm := strings.SplitN(o[1:], ":", 2)
+	h, err := strconv.Atoi(hm[0])
+	if err != nil || h < 0 || len(hm[0]) > 2 {
+		return "", nil, fmt.Errorf("%q isn't an offset in hours", tz)
+	}
+	m := 0
+	if len(hm) == 2 {
+		m, err = strconv.Atoi(hm[1])
+		if err != nil || m < 0 || m >= 60 || len(hm[1]) != 2 {
+			return "", nil, fmt.Errorf("%q isn't an offset in hours and minutes", tz)
+		}
+	}
+	d := sign * (time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	if d < minUTCOffset || d > maxUTCOffset {
+		return "", nil, fmt.Errorf("offset in %q should be between UTC-12 and UTC+14", tz)
+	}
+	if d == 0 {
+		return "UTC", time.UTC, nil
+	}
+	name := fmt.Sprintf("UTC%c%d", o[0], h)
+	if m != 0 {
+		name += fmt.Sprintf(":%02d", m)
+	}
+	return name, time.FixedZone(name, int(d/time.Second)), nil
+}
+
+// TimeZoneCommand asks the user either to share their location or to enter
+// the time zone.
+type TimeZoneCommand struct{}
+
+func (TimeZoneCommand) Ask(s *State, chatID int64) error {
+	return s.Telegram.SendKeyboardMessage(&KeyboardMessageReply{
+		ChatId: chatID,
+		Text:   "Share your location or enter your time zone, e.g. Europe/Budapest, UTC, UTC+3 or UTC-9:30.",
+		ReplyMarkup: &ReplyKeyboard{
+			Keyboard:        [][]*KeyboardButton{{{Text: "Share location", RequestLocation: true}}},
+			OneTimeKeyboard: true,
+			ResizeKeyboard:  true,
+		},
+	})
+}
+
+func (TimeZoneCommand) Validate(s *State, m *Message) error {
+	if m.Location != nil {
+		return nil
+	}
+	if err := s.Settings.ValidateTimeZone(m.Text); err != nil {
+		return UserError{ChatID: m.Chat.Id, Err: fmt.Errorf("%w. Please try again.", err)}
+	}
+	return nil
+}
+
+// Parse returns the time zone entered by the user or the one at the shared
+// location.
+func (TimeZoneCommand) Parse(_ *State, m *Message) (string, error) {
+	if m.Location == nil {
+		return m.Text, nil
+	}
+	return TimeZoneAt(m.Location.Latitude, m.Location.Longitude), nil
+}
+
+func (TimeZoneCommand) Save(s *State, chatID int64, answer string) error {
+	if err := s.Settings.SetTimeZone(chatID, answer); err != nil {
+		return err
+	}
+	settings, err := s.Settings.Get(chatID)
+	if err != nil {
+		return err
+	}
+	// Custom keyboard stays until it's removed explicitly.
+	if err := s.Telegram.SendKeyboardMessage(&KeyboardMessageReply{
+		ChatId:      chatID,
+		Text:        fmt.Sprintf("Time zone is set to %s.", settings.TimeZone),
+		ReplyMarkup: &ReplyKeyboard{RemoveKeyboard: true},
+	}); err != nil {
+		return err
+	}
+	return settingsReply(s, chatID)
+}
+
+// zoneCoordinates is a place within the time zone, usually its main city.
+type zoneCoordinates struct {
+	Zone      string
+	Latitude  float64
+	Longitude float64
+}
+
+// zoneTable is used to find the time zone by coordinates without calling
+// external services.
+var zoneTable = func() []zoneCoordinates {
+	zs, err := parseZoneTable(zoneTableData)
+	if err != nil {
+		panic(err)
+	}
+	return zs
+}()
+
+// parseZoneTable parses lines of the form "Europe/Budapest 47.50 19.08".
+func parseZoneTable(data string) ([]zoneCoordinates, error) {
+	var zs []zoneCoordinates
+	for i, l := range strings.Split(data, "\n") {
+		fs := strings.Fields(l)
+		if len(fs) == 0 || strings.HasPrefix(fs[0], "#") {
+			continue
+		}
+		if len(fs) != 3 {
+			return nil, fmt.Errorf("line %d: got %d fields; want zone, latitude and longitude", i+1, len(fs))
+		}
+		lat, err := strconv.ParseFloat(fs[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: latitude: %w", i+1, err)
+		}
+		lon, err := strconv.ParseFloat(fs[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: longitude: %w", i+1, err)
+		}
+		zs = append(zs, zoneCoordinates{Zone: fs[0], Latitude: lat, Longitude: lon})
+	}
+	return zs, nil
+}
+
+// TimeZoneAt returns the time zone of the closest place in the table. It's an
+// approximation which can be wrong close to the borders, users can always
+// enter the time zone themselves.
+func TimeZoneAt(latitude, longitude float64) string {
+	best, bestDist := "UTC", math.Inf(1)
+	for _, z := range zoneTable {
+		if d := distance(latitude, longitude, z.Latitude, z.Longitude); d < bestDist {
+			best, bestDist = z.Zone, d
+		}
+	}
+	return best
+}
+
+// distance returns the central angle between two points on a sphere given in
+// degrees.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	dLat, dLon := rad(lat2-lat1), rad(lon2-lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,275 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Offline table to find the time zone of the shared location.
+package main
+
+// zoneTableData lists a place in each time zone, as in zone1970.tab of the
+// IANA time zone database. Zones with several large cities far from each
+// other have an entry for each of them to make the lookup more precise.
+const zoneTableData = `
+# zone                          latitude  longitude
+Europe/Lisbon                   38.72     -9.13
+Atlantic/Azores                 37.73     -25.67
+Atlantic/Madeira                32.63     -16.90
+Atlantic/Canary                 28.10     -15.40
+Atlantic/Reykjavik              64.15     -21.85
+Europe/Dublin                   53.33     -6.25
+Europe/London                   51.51     -0.13
+Europe/London                   55.95     -3.19
+Europe/Madrid                   40.40     -3.68
+Europe/Madrid                   41.39     2.17
+Europe/Paris                    48.87     2.33
+Europe/Paris                    43.30     5.37
+Europe/Brussels                 50.83     4.33
+Europe/Amsterdam                52.37     4.90
+Europe/Luxembourg               49.60     6.15
+Europe/Zurich                   47.38     8.53
+Europe/Berlin                   52.50     13.37
+Europe/Berlin                   48.14     11.58
+Europe/Berlin                   53.55     9.99
+Europe/Copenhagen               55.67     12.58
+Europe/Oslo                     59.92     10.75
+Europe/Stockholm                59.33     18.05
+Europe/Helsinki                 60.17     24.97
+Europe/Tallinn                  59.42     24.75
+Europe/Riga                     56.95     24.10
+Europe/Vilnius                  54.68     25.32
+Europe/Warsaw                   52.25     21.00
+Europe/Warsaw                   50.06     19.94
+Europe/Prague                   50.08     14.43
+Europe/Bratislava               48.15     17.12
+Europe/Vienna                   48.22     16.33
+Europe/Budapest                 47.50     19.08
+Europe/Budapest                 46.25     20.15
+Europe/Ljubljana                46.05     14.52
+Europe/Zagreb                   45.80     15.97
+Europe/Sarajevo                 43.87     18.42
+Europe/Belgrade                 44.83     20.50
+Europe/Podgorica                42.43     19.27
+Europe/Skopje                   41.98     21.43
+Europe/Tirane                   41.33     19.83
+Europe/Rome                     41.90     12.48
+Europe/Rome                     45.46     9.19
+Europe/Malta                    35.90     14.52
+Europe/Athens                   37.97     23.72
+Europe/Sofia                    42.68     23.32
+Europe/Bucharest                44.43     26.10
+Europe/Chisinau                 47.00     28.83
+Europe/Kyiv                     50.43     30.52
+Europe/Kyiv                     49.84     24.03
+Europe/Kyiv                     46.48     30.73
+Europe/Kyiv                     49.99     36.23
+Europe/Minsk                    53.90     27.57
+Europe/Kaliningrad              54.72     20.50
+Europe/Moscow                   55.76     37.62
+Europe/Moscow                   59.94     30.31
+Europe/Moscow                   56.33     44.00
+Europe/Volgograd                48.73     44.42
+Europe/Samara                   53.20     50.15
+Europe/Istanbul                 41.02     28.97
+Europe/Istanbul                 39.93     32.86
+Asia/Nicosia                    35.17     33.37
+Asia/Yekaterinburg              56.85     60.60
+Asia/Omsk                       55.00     73.40
+Asia/Novosibirsk                55.03     82.92
+Asia/Krasnoyarsk                56.02     92.83
+Asia/Irkutsk                    52.27     104.33
+Asia/Yakutsk                    62.00     129.67
+Asia/Vladivostok                43.17     131.93
+Asia/Magadan                    59.57     150.80
+Asia/Kamchatka                  53.02     158.65
+Asia/Tbilisi                    41.72     44.82
+Asia/Yerevan                    40.18     44.50
+Asia/Baku                       40.38     49.85
+Asia/Jerusalem                  31.78     35.22
+Asia/Beirut                     33.88     35.50
+Asia/Damascus                   33.50     36.30
+Asia/Amman                      31.95     35.93
+Asia/Baghdad                    33.35     44.42
+Asia/Riyadh                     24.63     46.72
+Asia/Riyadh                     21.49     39.19
+Asia/Kuwait                     29.33     47.98
+Asia/Qatar                      25.28     51.53
+Asia/Dubai                      25.30     55.30
+Asia/Muscat                     23.60     58.58
+Asia/Aden                       12.75     45.20
+Asia/Tehran                     35.67     51.43
+Asia/Kabul                      34.52     69.20
+Asia/Tashkent                   41.33     69.30
+Asia/Almaty                     43.25     76.95
+Asia/Bishkek                    42.90     74.60
+Asia/Dushanbe                   38.58     68.80
+Asia/Ashgabat                   37.95     58.38
+Asia/Karachi                    24.87     67.05
+Asia/Karachi                    31.55     74.34
+Asia/Kolkata                    22.53     88.37
+Asia/Kolkata                    28.61     77.21
+Asia/Kolkata                    19.08     72.88
+Asia/Kolkata                    12.97     77.59
+Asia/Kathmandu                  27.72     85.32
+Asia/Thimphu                    27.47     89.65
+Asia/Dhaka                      23.72     90.42
+Asia/Colombo                    6.93      79.85
+Indian/Maldives                 4.17      73.50
+Asia/Yangon                     16.78     96.17
+Asia/Bangkok                    13.75     100.52
+Asia/Ho_Chi_Minh                10.75     106.67
+Asia/Bangkok                    21.03     105.85
+Asia/Phnom_Penh                 11.55     104.92
+Asia/Vientiane                  17.97     102.60
+Asia/Kuala_Lumpur               3.17      101.70
+Asia/Singapore                  1.28      103.85
+Asia/Jakarta                    -6.17     106.80
+Asia/Makassar                   -5.12     119.40
+Asia/Jayapura                   -2.53     140.70
+Asia/Manila                     14.58     121.00
+Asia/Shanghai                   31.23     121.47
+Asia/Shanghai                   39.90     116.40
+Asia/Shanghai                   23.13     113.26
+Asia/Shanghai                   30.57     104.07
+Asia/Urumqi                     43.80     87.58
+Asia/Hong_Kong                  22.28     114.15
+Asia/Taipei                     25.05     121.50
+Asia/Ulaanbaatar                47.92     106.88
+Asia/Seoul                      37.55     126.97
+Asia/Pyongyang                  39.02     125.75
+Asia/Tokyo                      35.65     139.73
+Asia/Tokyo                      34.69     135.50
+Asia/Tokyo                      43.06     141.35
+Asia/Dili                       -8.55     125.58
+Australia/Perth                 -31.95    115.85
+Australia/Darwin                -12.47    130.83
+Australia/Adelaide              -34.92    138.58
+Australia/Brisbane              -27.47    153.03
+Australia/Sydney                -33.87    151.22
+Australia/Melbourne             -37.82    144.97
+Australia/Hobart                -42.88    147.32
+Pacific/Auckland                -36.87    174.77
+Pacific/Auckland                -41.29    174.78
+Pacific/Chatham                 -43.95    -176.55
+Pacific/Port_Moresby            -9.50     147.17
+Pacific/Guadalcanal             -9.53     160.20
+Pacific/Noumea                  -22.27    166.45
+Pacific/Fiji                    -18.13    178.42
+Pacific/Tarawa                  1.42      173.00
+Pacific/Kiritimati              1.87      -157.33
+Pacific/Tongatapu               -21.13    -175.20
+Pacific/Apia                    -13.83    -171.73
+Pacific/Guam                    13.47     144.75
+Pacific/Majuro                  7.15      171.20
+Pacific/Tahiti                  -17.53    -149.57
+Pacific/Marquesas               -9.00     -139.50
+Pacific/Honolulu                21.31     -157.86
+America/Adak                    51.88     -176.66
+America/Anchorage               61.22     -149.90
+America/Juneau                  58.30     -134.42
+America/Vancouver               49.27     -123.12
+America/Los_Angeles             34.05     -118.24
+America/Los_Angeles             37.77     -122.42
+America/Los_Angeles             47.61     -122.33
+America/Los_Angeles             36.17     -115.14
+America/Tijuana                 32.53     -117.02
+America/Phoenix                 33.45     -112.07
+America/Denver                  39.74     -104.99
+America/Denver                  40.76     -111.89
+America/Boise                   43.61     -116.20
+America/Edmonton                53.55     -113.47
+America/Edmonton                51.05     -114.07
+America/Regina                  50.40     -104.65
+America/Winnipeg                49.88     -97.15
+America/Chicago                 41.85     -87.65
+America/Chicago                 29.76     -95.37
+America/Chicago                 32.78     -96.80
+America/Chicago                 44.98     -93.27
+America/Chicago                 38.63     -90.20
+America/Chicago                 29.95     -90.07
+America/Mexico_City             19.40     -99.15
+America/Monterrey               25.67     -100.32
+America/Cancun                  21.08     -86.77
+America/Guatemala               14.63     -90.52
+America/El_Salvador             13.70     -89.20
+America/Tegucigalpa             14.10     -87.22
+America/Managua                 12.15     -86.28
+America/Costa_Rica              9.93      -84.08
+America/Panama                  8.97      -79.53
+America/New_York                40.71     -74.01
+America/New_York                42.36     -71.06
+America/New_York                38.91     -77.04
+America/New_York                33.75     -84.39
+America/New_York                25.77     -80.19
+America/New_York                39.95     -75.17
+America/Detroit                 42.33     -83.05
+America/Toronto                 43.65     -79.38
+America/Toronto                 45.50     -73.57
+America/Halifax                 44.65     -63.60
+America/St_Johns                47.57     -52.72
+America/Havana                  23.13     -82.37
+America/Jamaica                 17.97     -76.80
+America/Port-au-Prince          18.53     -72.33
+America/Santo_Domingo           18.47     -69.90
+America/Puerto_Rico             18.47     -66.10
+America/Bogota                  4.60      -74.08
+America/Caracas                 10.50     -66.93
+America/Guayaquil               -2.17     -79.83
+America/Lima                    -12.05    -77.05
+America/La_Paz                  -16.50    -68.15
+America/Manaus                  -3.13     -60.02
+America/Sao_Paulo               -23.53    -46.62
+America/Sao_Paulo               -22.91    -43.17
+America/Sao_Paulo               -15.79    -47.88
+America/Fortaleza               -3.72     -38.50
+America/Recife                  -8.05     -34.90
+America/Belem                   -1.45     -48.48
+America/Asuncion                -25.27    -57.67
+America/Montevideo              -34.91    -56.21
+America/Argentina/Buenos_Aires  -34.60    -58.45
+America/Argentina/Cordoba       -31.40    -64.18
+America/Santiago                -33.45    -70.67
+America/Punta_Arenas            -53.15    -70.92
+America/Nuuk                    64.18     -51.73
+Africa/Casablanca               33.65     -7.58
+Africa/Algiers                  36.78     3.05
+Africa/Tunis                    36.80     10.18
+Africa/Tripoli                  32.90     13.18
+Africa/Cairo                    30.05     31.25
+Africa/Khartoum                 15.60     32.53
+Africa/Addis_Ababa              9.03      38.70
+Africa/Nairobi                  -1.28     36.82
+Africa/Dar_es_Salaam            -6.80     39.28
+Africa/Kampala                  0.32      32.42
+Africa/Kigali                   -1.95     30.07
+Africa/Kinshasa                 -4.30     15.30
+Africa/Lubumbashi               -11.67    27.47
+Africa/Luanda                   -8.80     13.23
+Africa/Lusaka                   -15.42    28.28
+Africa/Harare                   -17.83    31.05
+Africa/Maputo                   -25.97    32.58
+Africa/Johannesburg             -26.25    28.00
+Africa/Johannesburg             -33.92    18.42
+Africa/Windhoek                 -22.57    17.10
+Indian/Antananarivo             -18.92    47.52
+Indian/Mauritius                -20.17    57.50
+Africa/Lagos                    6.45      3.40
+Africa/Lagos                    9.06      7.49
+Africa/Accra                    5.55      -0.22
+Africa/Abidjan                  5.32      -4.03
+Africa/Dakar                    14.67     -17.43
+Africa/Bamako                   12.65     -8.00
+Africa/Niamey                   13.52     2.12
+Africa/Ndjamena                 12.12     15.05
+Africa/Douala                   4.05      9.70
+Atlantic/Cape_Verde             14.92     -23.52
+`
@@ -0,0 +1,123 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	for _, tc := range []struct {
+		tz, want string
+		offset   time.Duration
+	}{
+		{"UTC", "UTC", 0},
+		{"UTC+0", "UTC", 0},
+		{"UTC+3", "UTC+3", 3 * time.Hour},
+		{"UTC-11", "UTC-11", -11 * time.Hour},
+		{"UTC+05:30", "UTC+5:30", 5*time.Hour + 30*time.Minute},
+		{"UTC+5:45", "UTC+5:45", 5*time.Hour + 45*time.Minute},
+		{"UTC-9:30", "UTC-9:30", -9*time.Hour - 30*time.Minute},
+		{"UTC+14", "UTC+14", 14 * time.Hour},
+		{" Asia/Kathmandu ", "Asia/Kathmandu", 5*time.Hour + 45*time.Minute},
+	} {
+		got, loc, err := ParseTimeZone(tc.tz)
+		if err != nil {
+			t.Errorf("ParseTimeZone(%q): %v", tc.tz, err)
+			continue
+		}
+		_, offset := time.Date(2020, 1, 1, 0, 0, 0, 0, loc).Zone()
+		if got != tc.want || time.Duration(offset)*time.Second != tc.offset {
+			t.Errorf("ParseTimeZone(%q): got %q with offset %d; want %q with %v", tc.tz, got, offset, tc.want, tc.offset)
+		}
+	}
+	for _, tz := range []string{"UTC+15", "UTC-13", "UTC3", "UTC+5:5", "UTC+5:60", "Local", "Europe/Nowhere"} {
+		if _, _, err := ParseTimeZone(tz); err == nil {
+			t.Errorf("ParseTimeZone(%q): got nil error; want error", tz)
+		}
+	}
+}
+
+func TestTimeZoneDST(t *testing.T) {
+	s := &Settings{TimeZone: "Europe/Budapest"}
+	winter := time.Date(2020, 1, 15, 12, 0, 0, 0, time.UTC)
+	summer := time.Date(2020, 7, 15, 12, 0, 0, 0, time.UTC)
+	if got := winter.In(s.Location()).Hour(); got != 13 {
+		t.Errorf("winter: got hour %d; want 13", got)
+	}
+	if got := summer.In(s.Location()).Hour(); got != 14 {
+		t.Errorf("summer: got hour %d; want 14", got)
+	}
+}
+
+func TestTimeZoneAt(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		latitude, lon float64
+		want          string
+	}{
+		{"Szeged", 46.25, 20.15, "Europe/Budapest"},
+		{"Kraków", 50.06, 19.94, "Europe/Warsaw"},
+		{"Pune", 18.52, 73.86, "Asia/Kolkata"},
+		{"Pokhara", 28.21, 83.99, "Asia/Kathmandu"},
+		{"Sacramento", 38.58, -121.49, "America/Los_Angeles"},
+		{"Christchurch", -43.53, 172.64, "Pacific/Auckland"},
+	} {
+		if got := TimeZoneAt(tc.latitude, tc.lon); got != tc.want {
+			t.Errorf("TimeZoneAt(%s): got %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTimeZoneCommandLocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timezone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Temp dir: %q", dir)
+	defer os.RemoveAll(dir)
+
+	c, fk := startTestCommander(t, dir)
+	defer fk.server.Close()
+
+	const chatID int64 = 0
+	for _, m := range []*Message{
+		{Text: "/timezone"},
+		// Szeged.
+		{Location: &Location{Latitude: 46.25, Longitude: 20.15}},
+	} {
+		m.Chat.Id = chatID
+		if err := c.Update(&Update{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := c.Settings.Get(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TimeZone != "Europe/Budapest" {
+		t.Errorf("got time zone %q; want Europe/Budapest", s.TimeZone)
+	}
+	var texts []string
+	for _, m := range fk.messages {
+		texts = append(texts, m.Text)
+	}
+	if len(texts) < 2 || texts[1] != "Time zone is set to Europe/Budapest." {
+		t.Errorf("got messages %q; want the time zone confirmed after the question", texts)
+	}
+}
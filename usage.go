@@ -115,3 +115,33 @@ func (u *UsageFetcher) FetchExamples(word, language string, translationLanguages
 	// TODO: Prioritize using sentences with the most translations.
 	return ex, nil
 }
+
+// Forms returns lowercased forms of the word found in sentences of the
+// language. Words index contains surface forms, so inflected forms are
+// returned as well, see Tokenizer.isWordForm.
+func (u *UsageFetcher) Forms(word, language string, t Tokenizer) ([]string, error) {
+	stem := t.stem(word)
+	rows, err := u.db.Query(`
+		SELECT DISTINCT word
+		FROM Words
+		WHERE lang = ?
+		  AND word >= ?
+		  AND word < ?
+		ORDER BY word`,
+		language, stem, stem+"\U0010FFFF")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fs []string
+	for rows.Next() {
+		var f string
+		if err := rows.Scan(&f); err != nil {
+			return nil, err
+		}
+		if t.isWordForm(f, word) {
+			fs = append(fs, f)
+		}
+	}
+	return fs, rows.Err()
+}